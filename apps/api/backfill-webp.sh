@@ -16,14 +16,17 @@ else
   echo "No .env file found. Make sure DATABASE_URL and MEDIA_PATH are set."
 fi
 
-echo "Running WebP backfill..."
+# Derivative to backfill: webp (default), avif, mp4 or hover
+KIND="${1:-webp}"
+
+echo "Running ${KIND} backfill..."
 echo "MEDIA_PATH: ${MEDIA_PATH:-/mnt/storage/media}"
 
 cd "$SCRIPT_DIR"
 
 # Use compiled binary if available, otherwise fall back to go run
 if [ -f "./backfill-webp" ]; then
-  ./backfill-webp
+  ./backfill-webp -kind "$KIND"
 else
-  go run ./cmd/backfill-webp/main.go
+  go run ./cmd/backfill-webp/main.go -kind "$KIND"
 fi
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"storage-api/internal/config"
+	"storage-api/internal/db"
+	"storage-api/internal/handlers"
+	"storage-api/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+
+	gormDB, err := db.New(cfg.DSN)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close(gormDB)
+
+	ctx := context.Background()
+
+	// Find all items with a thumbnail but no placeholder
+	var items []models.MediaItem
+	result := gormDB.WithContext(ctx).
+		Where("thumbnail_path IS NOT NULL AND thumbnail_path != ''").
+		Where("blur_hash IS NULL OR blur_hash = ''").
+		Find(&items)
+
+	if result.Error != nil {
+		log.Fatalf("Failed to query media: %v", result.Error)
+	}
+
+	log.Printf("Found %d items to backfill", len(items))
+
+	mediaPath := os.Getenv("MEDIA_PATH")
+	if mediaPath == "" {
+		mediaPath = "/mnt/storage/media"
+	}
+
+	successCount := 0
+	errorCount := 0
+
+	for i, item := range items {
+		log.Printf("[%d/%d] Processing %s", i+1, len(items), item.Path)
+
+		thumbPath := fmt.Sprintf("%s/%s", mediaPath, item.ThumbnailPath)
+		blurHash, color, err := handlers.GeneratePlaceholder(thumbPath)
+		if err != nil {
+			log.Printf("  ERROR: %v", err)
+			errorCount++
+			continue
+		}
+
+		// Update database
+		updateResult := gormDB.WithContext(ctx).
+			Model(&models.MediaItem{}).
+			Where("id = ?", item.ID).
+			Updates(map[string]any{"blur_hash": blurHash, "dominant_color": color})
+
+		if updateResult.Error != nil {
+			log.Printf("  ERROR updating database: %v", updateResult.Error)
+			errorCount++
+			continue
+		}
+
+		log.Printf("  OK: %s %s", blurHash, color)
+		successCount++
+	}
+
+	log.Printf("Backfill complete: %d success, %d errors", successCount, errorCount)
+}
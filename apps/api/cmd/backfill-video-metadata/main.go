@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"storage-api/internal/config"
+	"storage-api/internal/db"
+	"storage-api/internal/handlers"
+	"storage-api/internal/models"
+)
+
+func main() {
+	cfg := config.Load()
+
+	gormDB, err := db.New(cfg.DSN)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close(gormDB)
+
+	ctx := context.Background()
+
+	// Find all videos that have not been probed
+	var videos []models.MediaItem
+	result := gormDB.WithContext(ctx).
+		Where("type = ?", "video").
+		Where("video_codec IS NULL OR video_codec = ''").
+		Find(&videos)
+
+	if result.Error != nil {
+		log.Fatalf("Failed to query videos: %v", result.Error)
+	}
+
+	log.Printf("Found %d videos to backfill", len(videos))
+
+	mediaPath := os.Getenv("MEDIA_PATH")
+	if mediaPath == "" {
+		mediaPath = "/mnt/storage/media"
+	}
+
+	successCount := 0
+	errorCount := 0
+
+	for i, video := range videos {
+		log.Printf("[%d/%d] Processing %s", i+1, len(videos), video.Path)
+
+		meta, err := handlers.ExtractVideoMetadata(fmt.Sprintf("%s/%s", mediaPath, video.Path))
+		if err != nil {
+			log.Printf("  ERROR: %v", err)
+			errorCount++
+			continue
+		}
+
+		fields := map[string]any{
+			"duration_sec": int(math.Round(meta.DurationSec)),
+			"width":        meta.Width,
+			"height":       meta.Height,
+			"rotation":     meta.Rotation,
+			"video_codec":  meta.VideoCodec,
+			"bitrate":      meta.Bitrate,
+			"frame_rate":   meta.FrameRate,
+		}
+
+		// Don't overwrite values that were already set (possibly by hand)
+		if video.TakenAt == nil && meta.TakenAt != nil {
+			fields["taken_at"] = meta.TakenAt.UTC()
+		}
+		if video.Latitude == nil && meta.Latitude != nil {
+			fields["latitude"] = *meta.Latitude
+			fields["longitude"] = *meta.Longitude
+		}
+		if video.CameraMake == "" && meta.CameraMake != "" {
+			fields["camera_make"] = meta.CameraMake
+			fields["camera_model"] = meta.CameraModel
+		}
+
+		// Update database
+		updateResult := gormDB.WithContext(ctx).
+			Model(&models.MediaItem{}).
+			Where("id = ?", video.ID).
+			Updates(fields)
+
+		if updateResult.Error != nil {
+			log.Printf("  ERROR updating database: %v", updateResult.Error)
+			errorCount++
+			continue
+		}
+
+		log.Printf("  OK: %s %dx%d %.1fs", meta.VideoCodec, meta.Width, meta.Height, meta.DurationSec)
+		successCount++
+	}
+
+	log.Printf("Backfill complete: %d success, %d errors", successCount, errorCount)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -12,7 +13,53 @@ import (
 	"storage-api/internal/models"
 )
 
+// derivative describes a web-optimized format that can be backfilled
+type derivative struct {
+	mediaType string // "photo" or "video"
+	column    string // storage_items column holding the relative path
+	generate  func(srcPath, originalRelPath string) (fullPath, relPath string, err error)
+
+	// generateAnimated makes the derivative for animated GIFs. Without it
+	// animated photos are skipped, since generate would keep only the first
+	// frame. Animated WebP originals are always skipped; they are served as-is.
+	generateAnimated func(srcPath, originalRelPath string) (fullPath, relPath string, err error)
+}
+
+var derivatives = map[string]derivative{
+	"webp": {
+		mediaType:        "photo",
+		column:           "web_path",
+		generate:         handlers.GenerateWebOptimizedImage,
+		generateAnimated: handlers.GenerateAnimatedWebP,
+	},
+	"avif":  {mediaType: "photo", column: "avif_path", generate: handlers.GenerateAVIFImage},
+	"mp4":   {mediaType: "video", column: "web_path", generate: generateWebVideo},
+	"hover": {mediaType: "video", column: "animated_thumbnail_path", generate: generateHoverPreview},
+}
+
+// generateWebVideo transcodes in the foreground rather than queueing
+func generateWebVideo(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	return handlers.GenerateWebVideo(context.Background(), srcPath, originalRelPath)
+}
+
+// generateHoverPreview probes the video's duration to pick the preview clip
+func generateHoverPreview(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	meta, err := handlers.ExtractVideoMetadata(srcPath)
+	if err != nil {
+		return "", "", err
+	}
+	return handlers.GenerateVideoHoverPreview(srcPath, originalRelPath, meta.DurationSec)
+}
+
 func main() {
+	kind := flag.String("kind", "webp", "derivative to generate: webp, avif, mp4 or hover")
+	flag.Parse()
+
+	d, ok := derivatives[*kind]
+	if !ok {
+		log.Fatalf("Unknown kind %q (want webp, avif, mp4 or hover)", *kind)
+	}
+
 	cfg := config.Load()
 
 	gormDB, err := db.New(cfg.DSN)
@@ -23,18 +70,23 @@ func main() {
 
 	ctx := context.Background()
 
-	// Find all photos without web_path
-	var photos []models.MediaItem
-	result := gormDB.WithContext(ctx).
-		Where("type = ?", "photo").
-		Where("web_path IS NULL OR web_path = ''").
-		Find(&photos)
+	// Find all items of the derivative's type without it
+	var items []models.MediaItem
+	query := gormDB.WithContext(ctx).
+		Where("type = ?", d.mediaType).
+		Where(fmt.Sprintf("%s IS NULL OR %s = ''", d.column, d.column))
+	if d.generateAnimated == nil {
+		query = query.Where("NOT is_animated")
+	} else {
+		query = query.Where("NOT is_animated OR mime_type = ?", "image/gif")
+	}
+	result := query.Find(&items)
 
 	if result.Error != nil {
-		log.Fatalf("Failed to query photos: %v", result.Error)
+		log.Fatalf("Failed to query %ss: %v", d.mediaType, result.Error)
 	}
 
-	log.Printf("Found %d photos to backfill", len(photos))
+	log.Printf("Found %d %ss to backfill (%s)", len(items), d.mediaType, *kind)
 
 	mediaPath := os.Getenv("MEDIA_PATH")
 	if mediaPath == "" {
@@ -44,18 +96,22 @@ func main() {
 	successCount := 0
 	errorCount := 0
 
-	for i, photo := range photos {
-		log.Printf("[%d/%d] Processing %s", i+1, len(photos), photo.Path)
+	for i, item := range items {
+		log.Printf("[%d/%d] Processing %s", i+1, len(items), item.Path)
 
 		// Determine source path (use preview for HEIC, otherwise original)
-		srcPath := photo.Path
-		if photo.PreviewPath != "" {
-			srcPath = photo.PreviewPath
+		srcPath := item.Path
+		if item.PreviewPath != "" {
+			srcPath = item.PreviewPath
 		}
 		fullSrcPath := fmt.Sprintf("%s/%s", mediaPath, srcPath)
 
-		// Generate WebP
-		webFull, webRel, err := handlers.GenerateWebOptimizedImage(fullSrcPath, photo.Path)
+		// Generate the derivative
+		generate := d.generate
+		if item.IsAnimated {
+			generate = d.generateAnimated
+		}
+		genFull, genRel, err := generate(fullSrcPath, item.Path)
 		if err != nil {
 			log.Printf("  ERROR: %v", err)
 			errorCount++
@@ -65,17 +121,17 @@ func main() {
 		// Update database
 		updateResult := gormDB.WithContext(ctx).
 			Model(&models.MediaItem{}).
-			Where("id = ?", photo.ID).
-			Update("web_path", webRel)
+			Where("id = ?", item.ID).
+			Update(d.column, genRel)
 
 		if updateResult.Error != nil {
 			log.Printf("  ERROR updating database: %v", updateResult.Error)
-			os.Remove(webFull) // Clean up generated file
+			os.Remove(genFull) // Clean up generated file
 			errorCount++
 			continue
 		}
 
-		log.Printf("  OK: %s", webRel)
+		log.Printf("  OK: %s", genRel)
 		successCount++
 	}
 
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"storage-api/internal/config"
+	"storage-api/internal/db"
+	"storage-api/internal/handlers"
+	"storage-api/internal/repository"
+	"storage-api/internal/service"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: storagectl <command> [flags]
+
+Commands:
+  export -household <id> -out <file.zip>   Write a portable archive of a household
+  import -in <file.zip>                    Restore a household archive
+`
+
+// services bundles the services used by storagectl commands
+type services struct {
+	household *service.HouseholdService
+	user      *service.UserService
+	media     *service.MediaService
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func connect() (*gorm.DB, *services) {
+	cfg := config.Load()
+
+	gormDB, err := db.New(cfg.DSN)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	return gormDB, &services{
+		household: service.NewHouseholdService(repository.NewHouseholdRepository(gormDB)),
+		user:      service.NewUserService(repository.NewUserRepository(gormDB)),
+		media:     service.NewMediaService(repository.NewMediaRepository(gormDB)),
+	}
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	householdFlag := fs.String("household", "", "household ID to export")
+	outFlag := fs.String("out", "", "output archive path")
+	_ = fs.Parse(args)
+
+	householdID, err := uuid.Parse(*householdFlag)
+	if err != nil || *outFlag == "" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	gormDB, svcs := connect()
+	defer db.Close(gormDB)
+
+	ctx := context.Background()
+	export, err := handlers.BuildHouseholdExport(ctx, svcs.household, svcs.user, svcs.media, householdID)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	out, err := os.Create(*outFlag)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *outFlag, err)
+	}
+
+	if err := handlers.WriteHouseholdExport(ctx, out, export); err != nil {
+		out.Close()
+		os.Remove(*outFlag)
+		log.Fatalf("Export failed: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("Failed to write %s: %v", *outFlag, err)
+	}
+
+	log.Printf("Exported %q: %d users, %d items to %s",
+		export.Household.Name, len(export.Users), len(export.Items), *outFlag)
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	inFlag := fs.String("in", "", "archive to import")
+	_ = fs.Parse(args)
+
+	if *inFlag == "" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	gormDB, svcs := connect()
+	defer db.Close(gormDB)
+
+	result, err := handlers.ImportHouseholdArchive(context.Background(), *inFlag, svcs.household, svcs.user, svcs.media)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	summary, _ := json.MarshalIndent(result, "", "  ")
+	log.Printf("Import complete:\n%s", summary)
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
@@ -12,6 +12,8 @@ git pull origin main
 echo "🔨 Building application..."
 go build -o storage-api ./cmd/server
 go build -o backfill-webp ./cmd/backfill-webp
+go build -o backfill-placeholders ./cmd/backfill-placeholders
+go build -o backfill-video-metadata ./cmd/backfill-video-metadata
 
 echo "🗄️  Running migrations..."
 ./migrate.sh
@@ -1,11 +1,46 @@
 package config
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Addr           string
 	DSN            string
 	ClerkSecretKey string
+
+	// TrashRetention is how long deleted items stay in the trash before
+	// being purged. Zero disables automatic purging.
+	TrashRetention time.Duration
+
+	// MediaURLKeys sign and verify short-lived media URLs. The first key signs
+	// new URLs; the others are only accepted when verifying, so a key can be
+	// rotated by prepending the new one and dropping the old one a TTL later.
+	MediaURLKeys []SigningKey
+	MediaURLTTL  time.Duration
+
+	// HLSMinDuration is the shortest video that gets an HLS adaptive stream.
+	// Zero disables HLS generation.
+	HLSMinDuration time.Duration
+
+	// UploadAllowedTypes are the MIME types accepted for upload, detected from
+	// file content. Entries may be a category ("image/*"). Empty means the
+	// built-in list of everything the server can process.
+	UploadAllowedTypes []string
+
+	// MaxImagePixels is the largest image (width × height) the server decodes.
+	// Zero disables the check.
+	MaxImagePixels int
+}
+
+// SigningKey is an HMAC key with an identifier that is embedded in signed URLs
+type SigningKey struct {
+	ID     string
+	Secret []byte
 }
 
 func Load() Config {
@@ -13,7 +48,45 @@ func Load() Config {
 		Addr:           getenv("ADDR", ":8080"),
 		DSN:            getenv("DATABASE_URL", ""),
 		ClerkSecretKey: getenv("CLERK_SECRET_KEY", ""),
+		TrashRetention: time.Duration(getenvInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		MediaURLKeys:   parseSigningKeys(getenv("MEDIA_URL_KEYS", "")),
+		MediaURLTTL:    time.Duration(getenvInt("MEDIA_URL_TTL_MINUTES", 60)) * time.Minute,
+		HLSMinDuration: time.Duration(getenvInt("HLS_MIN_DURATION_SECONDS", 120)) * time.Second,
+
+		UploadAllowedTypes: parseList(getenv("UPLOAD_ALLOWED_TYPES", "")),
+		MaxImagePixels:     getenvInt("MAX_IMAGE_PIXELS", 100_000_000),
+	}
+}
+
+// parseList parses a comma-separated list, lowercasing entries and skipping
+// empty ones
+func parseList(v string) []string {
+	var list []string
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// parseSigningKeys parses a comma-separated list of "id:secret" pairs.
+// Malformed entries are skipped with a warning.
+func parseSigningKeys(v string) []SigningKey {
+	var keys []SigningKey
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || len(secret) < 16 {
+			log.Printf("WARNING: ignoring malformed MEDIA_URL_KEYS entry (want id:secret, secret at least 16 chars)")
+			continue
+		}
+		keys = append(keys, SigningKey{ID: id, Secret: []byte(secret)})
 	}
+	return keys
 }
 
 func getenv(key, fallback string) string {
@@ -23,3 +96,11 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+func getenvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/google/uuid"
+)
+
+type SmartAlbumHandler struct {
+	svc     *service.SmartAlbumService
+	userSvc *service.UserService
+	signer  *service.MediaURLSigner
+}
+
+func NewSmartAlbumHandler(svc *service.SmartAlbumService, userSvc *service.UserService, signer *service.MediaURLSigner) *SmartAlbumHandler {
+	return &SmartAlbumHandler{svc: svc, userSvc: userSvc, signer: signer}
+}
+
+// smartAlbumRequest is the request body for creating or updating a smart album
+type smartAlbumRequest struct {
+	Name  string                 `json:"name"`
+	Rules models.SmartAlbumRules `json:"rules"`
+}
+
+// getAlbum fetches a smart album by the {id} URL parameter and checks that it
+// belongs to the household. Returns the album, or writes an error response and returns nil.
+func (h *SmartAlbumHandler) getAlbum(w http.ResponseWriter, r *http.Request, householdID uuid.UUID) *models.SmartAlbum {
+	id, ok := parseUUIDParam(w, r, "id")
+	if !ok {
+		return nil
+	}
+
+	album, err := h.svc.GetByID(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get smart album: %v", err),
+		})
+		return nil
+	}
+	if err != nil || album.HouseholdID != householdID {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "smart album not found",
+		})
+		return nil
+	}
+	return album
+}
+
+// writeAlbumSaveError writes the response for a failed create or update
+func writeAlbumSaveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrInvalidInput) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]any{
+		"error": fmt.Sprintf("failed to save smart album: %v", err),
+	})
+}
+
+// List handles GET /smart-albums
+func (h *SmartAlbumHandler) List(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	albums, err := h.svc.List(r.Context(), householdID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list smart albums: %v", err),
+		})
+		return
+	}
+
+	if albums == nil {
+		albums = []models.SmartAlbum{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"albums": albums})
+}
+
+// Create handles POST /smart-albums
+func (h *SmartAlbumHandler) Create(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	var req smartAlbumRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	album := &models.SmartAlbum{
+		HouseholdID: householdID,
+		Name:        req.Name,
+		Rules:       req.Rules,
+	}
+	if u := lookupCurrentUser(r, h.userSvc); u != nil {
+		album.OwnerID = &u.ID
+	}
+
+	if err := h.svc.Create(r.Context(), album); err != nil {
+		writeAlbumSaveError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{"album": album})
+}
+
+// Get handles GET /smart-albums/{id}
+func (h *SmartAlbumHandler) Get(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	album := h.getAlbum(w, r, householdID)
+	if album == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"album": album})
+}
+
+// Update handles PUT /smart-albums/{id}
+// Only the album owner or a household admin may change it.
+func (h *SmartAlbumHandler) Update(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	album := h.getAlbum(w, r, householdID)
+	if album == nil {
+		return
+	}
+
+	if !canManage(lookupCurrentUser(r, h.userSvc), album.OwnerID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "not allowed to modify this smart album",
+		})
+		return
+	}
+
+	var req smartAlbumRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	album.Name = req.Name
+	album.Rules = req.Rules
+
+	if err := h.svc.Update(r.Context(), album); err != nil {
+		writeAlbumSaveError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"album": album})
+}
+
+// Delete handles DELETE /smart-albums/{id}
+// Only the album owner or a household admin may delete it.
+func (h *SmartAlbumHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	album := h.getAlbum(w, r, householdID)
+	if album == nil {
+		return
+	}
+
+	if !canManage(lookupCurrentUser(r, h.userSvc), album.OwnerID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "not allowed to delete this smart album",
+		})
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), album.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to delete smart album: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "deleted successfully",
+	})
+}
+
+// Media handles GET /smart-albums/{id}/media
+// Evaluates the album's rules live and returns a page of matching items.
+func (h *SmartAlbumHandler) Media(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	album := h.getAlbum(w, r, householdID)
+	if album == nil {
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+
+	var userID *uuid.UUID
+	if u := lookupCurrentUser(r, h.userSvc); u != nil {
+		userID = &u.ID
+	}
+
+	items, totalCount, err := h.svc.ListMedia(r.Context(), album, userID, page, pageSize)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list smart album media: %v", err),
+		})
+		return
+	}
+
+	if items == nil {
+		items = []models.MediaItem{}
+	}
+	signItems(h.signer, items)
+
+	writeJSON(w, http.StatusOK, models.MediaListResponse{
+		Items:      items,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+	})
+}
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Limits for generating looping thumbnails; larger animations only get a
+// static thumbnail so a single upload cannot exhaust memory.
+const (
+	MaxAnimatedThumbnailFrames = 300
+	MaxAnimatedThumbnailPixels = 400 << 20 // width * height * frames
+)
+
+// AnimationInfo describes an animated image
+type AnimationInfo struct {
+	Format     string // "gif" or "webp"
+	FrameCount int
+	DurationMs int // Length of one loop
+	Width      int
+	Height     int
+}
+
+// DetectAnimation inspects a GIF or WebP file and returns its animation info,
+// or nil if the file is not an animated image.
+func DetectAnimation(path string) (*AnimationInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(12)
+	if err != nil {
+		return nil, nil
+	}
+
+	var info *AnimationInfo
+	switch {
+	case strings.HasPrefix(string(magic), "GIF8"):
+		info, err = detectGIFAnimation(r)
+	case string(magic[:4]) == "RIFF" && string(magic[8:12]) == "WEBP":
+		info, err = detectWebPAnimation(r)
+	}
+	if err != nil || info == nil || info.FrameCount < 2 {
+		return nil, err
+	}
+	return info, nil
+}
+
+func detectGIFAnimation(r *bufio.Reader) (*AnimationInfo, error) {
+	info, _, err := scanGIF(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gif: %w", err)
+	}
+	return info, nil
+}
+
+// scanGIF walks the blocks of a GIF without decoding any pixels. It counts the
+// frames, sums their delays and returns the pixels decoding every frame takes.
+func scanGIF(r *bufio.Reader) (info *AnimationInfo, framePixels int64, err error) {
+	// Header and logical screen descriptor
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	info = &AnimationInfo{
+		Format: "gif",
+		Width:  int(binary.LittleEndian.Uint16(header[6:8])),
+		Height: int(binary.LittleEndian.Uint16(header[8:10])),
+	}
+	if err := skipGIFColorTable(r, header[10]); err != nil {
+		return nil, 0, err
+	}
+
+	// The graphic control extension sets the delay of the next image only
+	delay := 0
+	for {
+		block, err := r.ReadByte()
+		if err != nil {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+
+		switch block {
+		case 0x21: // Extension
+			label, err := r.ReadByte()
+			if err != nil {
+				return nil, 0, io.ErrUnexpectedEOF
+			}
+			if label == 0xF9 {
+				// Block size, flags, delay, transparent index, terminator
+				var gce [6]byte
+				if _, err := io.ReadFull(r, gce[:]); err != nil {
+					return nil, 0, io.ErrUnexpectedEOF
+				}
+				if gce[0] != 4 || gce[5] != 0 {
+					return nil, 0, errors.New("invalid graphic control extension")
+				}
+				delay = int(binary.LittleEndian.Uint16(gce[2:4]))
+				continue
+			}
+			if err := skipGIFSubBlocks(r); err != nil {
+				return nil, 0, err
+			}
+		case 0x2C: // Image descriptor: left, top, width, height, flags
+			var desc [9]byte
+			if _, err := io.ReadFull(r, desc[:]); err != nil {
+				return nil, 0, io.ErrUnexpectedEOF
+			}
+			if err := skipGIFColorTable(r, desc[8]); err != nil {
+				return nil, 0, err
+			}
+			// LZW minimum code size, then the compressed data
+			if _, err := r.ReadByte(); err != nil {
+				return nil, 0, io.ErrUnexpectedEOF
+			}
+			if err := skipGIFSubBlocks(r); err != nil {
+				return nil, 0, err
+			}
+			width := int64(binary.LittleEndian.Uint16(desc[4:6]))
+			height := int64(binary.LittleEndian.Uint16(desc[6:8]))
+			info.FrameCount++
+			info.DurationMs += delay * 10
+			framePixels += width * height
+			delay = 0
+		case 0x3B: // Trailer
+			return info, framePixels, nil
+		default:
+			return nil, 0, fmt.Errorf("unknown gif block 0x%02x", block)
+		}
+	}
+}
+
+// skipGIFColorTable skips the color table a descriptor's flags announce
+func skipGIFColorTable(r *bufio.Reader, flags byte) error {
+	if flags&0x80 == 0 {
+		return nil
+	}
+	if _, err := r.Discard(3 << (flags&0x07 + 1)); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// skipGIFSubBlocks skips length-prefixed data sub-blocks up to the terminator
+func skipGIFSubBlocks(r *bufio.Reader) error {
+	for {
+		n, err := r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if n == 0 {
+			return nil
+		}
+		if _, err := r.Discard(int(n)); err != nil {
+			return io.ErrUnexpectedEOF
+		}
+	}
+}
+
+// detectWebPAnimation walks the RIFF chunks of an extended WebP and sums the
+// durations of its ANMF (animation frame) chunks.
+func detectWebPAnimation(r *bufio.Reader) (*AnimationInfo, error) {
+	if _, err := r.Discard(12); err != nil {
+		return nil, err
+	}
+
+	info := &AnimationInfo{Format: "webp"}
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return info, nil
+			}
+			return nil, err
+		}
+		typ := string(header[:4])
+		length := int(binary.LittleEndian.Uint32(header[4:8]))
+		padded := length + length&1
+
+		switch typ {
+		case "VP8 ", "VP8L":
+			// Simple (single image) format
+			return nil, nil
+		case "VP8X":
+			var payload [10]byte
+			if length < len(payload) {
+				return nil, errors.New("invalid VP8X chunk")
+			}
+			if _, err := io.ReadFull(r, payload[:]); err != nil {
+				return nil, err
+			}
+			if payload[0]&0x02 == 0 {
+				return nil, nil // Animation flag not set
+			}
+			info.Width = int(uint24(payload[4:7])) + 1
+			info.Height = int(uint24(payload[7:10])) + 1
+			padded -= len(payload)
+		case "ANMF":
+			// Frame X, Y, width, height, then a 24-bit duration in milliseconds
+			var payload [15]byte
+			if length < len(payload) {
+				return nil, errors.New("invalid ANMF chunk")
+			}
+			if _, err := io.ReadFull(r, payload[:]); err != nil {
+				return nil, err
+			}
+			info.FrameCount++
+			info.DurationMs += int(uint24(payload[12:15]))
+			padded -= len(payload)
+		}
+
+		if _, err := r.Discard(padded); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// uint24 decodes a little-endian 24-bit integer
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// GenerateAnimatedWebP converts an animated GIF to an animated WebP for web viewing.
+// Uses the gif2webp CLI tool from libwebp.
+func GenerateAnimatedWebP(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	fullPath, relPath = getWebPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create web directory: %w", err)
+	}
+
+	cmd := exec.Command("gif2webp",
+		"-q", fmt.Sprintf("%d", WebOptimizedQuality),
+		"-m", "4",
+		"-mixed",
+		srcPath,
+		"-o", fullPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("gif2webp failed: %v, output: %s", err, string(output))
+	}
+
+	return fullPath, relPath, nil
+}
+
+func getAnimatedThumbnailPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".thumbs", relativePath, ".anim.gif")
+}
+
+// errStopFrames ends compositeFrames early without an error
+var errStopFrames = errors.New("stop")
+
+// compositeFrames draws each frame of an animated GIF or WebP in turn, honoring
+// disposal and blending, and calls fn with the canvas and the frame's delay in
+// milliseconds. The canvas is reused, so fn must copy what it keeps.
+func compositeFrames(path string, anim *AnimationInfo, fn func(canvas *image.NRGBA, delayMs int) error) error {
+	var err error
+	switch anim.Format {
+	case "gif":
+		err = compositeGIF(path, fn)
+	case "webp":
+		err = compositeWebP(path, fn)
+	default:
+		err = fmt.Errorf("unsupported animation format %q", anim.Format)
+	}
+	if errors.Is(err, errStopFrames) {
+		return nil
+	}
+	return err
+}
+
+func compositeGIF(path string, fn func(canvas *image.NRGBA, delayMs int) error) error {
+	src, err := decodeGIF(path)
+	if err != nil {
+		return fmt.Errorf("failed to decode gif: %w", err)
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, src.Config.Width, src.Config.Height))
+	for i, frame := range src.Image {
+		var previous *image.NRGBA
+		disposal := byte(gif.DisposalNone)
+		if i < len(src.Disposal) {
+			disposal = src.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = imaging.Clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if err := fn(canvas, src.Delay[i]*10); err != nil {
+			return err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return nil
+}
+
+// compositeWebP decodes the frames of an animated WebP one at a time. Each
+// ANMF chunk holds a still WebP bitstream, which is rewrapped as a file on
+// its own for Go's decoder.
+func compositeWebP(path string, fn func(canvas *image.NRGBA, delayMs int) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if _, err := r.Discard(12); err != nil {
+		return err
+	}
+
+	var canvas *image.NRGBA
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		typ := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:8]))
+		padded := length + length&1
+
+		switch typ {
+		case "VP8X":
+			var payload [10]byte
+			if length < int64(len(payload)) {
+				return errors.New("invalid VP8X chunk")
+			}
+			if _, err := io.ReadFull(r, payload[:]); err != nil {
+				return err
+			}
+			width := int(uint24(payload[4:7])) + 1
+			height := int(uint24(payload[7:10])) + 1
+			if maxImagePixels > 0 && int64(width)*int64(height) > int64(maxImagePixels) {
+				return fmt.Errorf("%w: %dx%d is over the limit of %d", ErrImageTooLarge, width, height, maxImagePixels)
+			}
+			canvas = image.NewNRGBA(image.Rect(0, 0, width, height))
+			padded -= int64(len(payload))
+		case "ANMF":
+			if canvas == nil {
+				return errors.New("ANMF chunk before VP8X")
+			}
+			// Read what is there rather than trusting the length up front
+			payload, err := io.ReadAll(io.LimitReader(r, padded))
+			if err != nil {
+				return err
+			}
+			if int64(len(payload)) < length || length < 16 {
+				return errors.New("invalid ANMF chunk")
+			}
+			if err := drawWebPFrame(canvas, payload[:length], fn); err != nil {
+				return err
+			}
+			padded = 0
+		}
+
+		if _, err := r.Discard(int(padded)); err != nil {
+			return err
+		}
+	}
+}
+
+// drawWebPFrame draws one ANMF payload onto the canvas, calls fn, then
+// applies the frame's disposal
+func drawWebPFrame(canvas *image.NRGBA, payload []byte, fn func(canvas *image.NRGBA, delayMs int) error) error {
+	x := int(uint24(payload[0:3])) * 2
+	y := int(uint24(payload[3:6])) * 2
+	width := int(uint24(payload[6:9])) + 1
+	height := int(uint24(payload[9:12])) + 1
+	delayMs := int(uint24(payload[12:15]))
+	noBlend := payload[15]&0x02 != 0
+	disposeToBackground := payload[15]&0x01 != 0
+
+	bounds := image.Rect(x, y, x+width, y+height)
+	if !bounds.In(canvas.Bounds()) {
+		return errors.New("animation frame outside the canvas")
+	}
+
+	frame, err := decodeImage(standaloneWebP(payload[16:], width, height))
+	if err != nil {
+		return fmt.Errorf("failed to decode frame: %w", err)
+	}
+
+	op := draw.Over
+	if noBlend {
+		op = draw.Src
+	}
+	draw.Draw(canvas, bounds, frame, frame.Bounds().Min, op)
+	if err := fn(canvas, delayMs); err != nil {
+		return err
+	}
+
+	if disposeToBackground {
+		draw.Draw(canvas, bounds, image.Transparent, image.Point{}, draw.Src)
+	}
+	return nil
+}
+
+// standaloneWebP wraps the chunks of an animation frame (an optional ALPH
+// chunk and a VP8 or VP8L chunk) into a still WebP file
+func standaloneWebP(chunks []byte, width, height int) []byte {
+	var body []byte
+	body = append(body, "WEBP"...)
+	if len(chunks) >= 4 && string(chunks[:4]) == "ALPH" {
+		// Lossy frames with alpha need the extended format's header
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10 // Alpha
+		putUint24(vp8x[4:7], uint32(width-1))
+		putUint24(vp8x[7:10], uint32(height-1))
+		body = append(body, "VP8X"...)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(vp8x)))
+		body = append(body, vp8x...)
+	}
+	body = append(body, chunks...)
+
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(file, body...)
+}
+
+// putUint24 encodes a little-endian 24-bit integer
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// GenerateFirstFrameThumbnail creates the still thumbnail of an animated WebP
+// from its first frame
+func GenerateFirstFrameThumbnail(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	var first *image.NRGBA
+	err = compositeFrames(srcPath, &AnimationInfo{Format: "webp"}, func(canvas *image.NRGBA, _ int) error {
+		first = canvas
+		return errStopFrames
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if first == nil {
+		return "", "", errors.New("animation has no frames")
+	}
+	return saveImageThumbnail(first, originalRelPath)
+}
+
+// GenerateAnimatedThumbnail creates a looping 300px GIF thumbnail from an
+// animated GIF or WebP. Frames are composited (honoring disposal) before
+// resizing, so partial frames come out right.
+func GenerateAnimatedThumbnail(srcPath, originalRelPath string, anim *AnimationInfo) (fullPath, relPath string, err error) {
+	if anim.FrameCount > MaxAnimatedThumbnailFrames || anim.Width*anim.Height*anim.FrameCount > MaxAnimatedThumbnailPixels {
+		return "", "", errors.New("animation too large for a looping thumbnail")
+	}
+
+	out := &gif.GIF{LoopCount: 0}
+	err = compositeFrames(srcPath, anim, func(canvas *image.NRGBA, delayMs int) error {
+		thumb := imaging.Fit(canvas, ThumbnailSize, ThumbnailSize, imaging.Lanczos)
+		paletted := image.NewPaletted(thumb.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, thumb.Bounds(), thumb, image.Point{})
+
+		out.Image = append(out.Image, paletted)
+		out.Delay = append(out.Delay, delayMs/10)
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if len(out.Image) == 0 {
+		return "", "", errors.New("animation has no frames")
+	}
+
+	fullPath, relPath = getAnimatedThumbnailPath(originalRelPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create thumbnail: %w", err)
+	}
+	defer dst.Close()
+
+	if err := gif.EncodeAll(dst, out); err != nil {
+		os.Remove(fullPath)
+		return "", "", fmt.Errorf("failed to save animated thumbnail: %w", err)
+	}
+
+	return fullPath, relPath, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// MaxArchiveBatch limits how many items can be archived in one request
+const MaxArchiveBatch = 500
+
+// archiveRequest is the request body for bulk archive/unarchive
+type archiveRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+// Archive handles POST /media/archive
+func (h *MediaHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// Unarchive handles POST /media/unarchive
+func (h *MediaHandler) Unarchive(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+// setArchived updates the archive state of every requested item the caller
+// can manage (own uploads, or any item for admins). Other IDs are reported as skipped.
+func (h *MediaHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser := h.getCurrentUser(r)
+	if currentUser == nil || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return
+	}
+
+	var req archiveRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > MaxArchiveBatch {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": fmt.Sprintf("ids must contain between 1 and %d items", MaxArchiveBatch),
+		})
+		return
+	}
+
+	items, err := h.svc.GetByIDs(r.Context(), req.IDs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+
+	allowed := make(map[uuid.UUID]bool, len(items))
+	var ids []uuid.UUID
+	for i := range items {
+		item := &items[i]
+		if item.HouseholdID == householdID && canManage(currentUser, item.UploaderID) {
+			allowed[item.ID] = true
+			ids = append(ids, item.ID)
+		}
+	}
+
+	skipped := []uuid.UUID{}
+	for _, id := range req.IDs {
+		if !allowed[id] {
+			skipped = append(skipped, id)
+		}
+	}
+
+	updated, err := h.svc.SetArchived(r.Context(), ids, archived)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to update archive state: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updated": updated,
+		"skipped": skipped,
+	})
+}
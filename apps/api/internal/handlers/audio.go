@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+)
+
+// Waveform image dimensions; thumbnails are scaled down from these
+const (
+	WaveformWidth  = 1200
+	WaveformHeight = 400
+)
+
+// WaveformColor is the color of the waveform drawn on a white background
+const WaveformColor = "0x4f46e5"
+
+// WebAudioBitrate is the AAC bitrate of web renditions
+const WebAudioBitrate = "128k"
+
+// webAudioMaxBitrate is the highest bitrate (bits per second) at which an
+// AAC or MP3 original is streamed as-is instead of getting a web rendition
+const webAudioMaxBitrate = 192_000
+
+// audioMimeTypes maps audio extensions to their MIME types
+var audioMimeTypes = map[string]string{
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".opus": "audio/ogg",
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+}
+
+// AudioMetadata contains metadata extracted from an audio file by ffprobe
+type AudioMetadata struct {
+	DurationSec float64
+	Codec       string
+	Bitrate     int64 // Bits per second, whole file
+	Title       string
+	Artist      string
+	Album       string
+	TakenAt     *time.Time // Recording date, e.g. from Voice Memos
+}
+
+// IsAudio checks if the MIME type indicates an audio file
+func IsAudio(mimeType string) bool {
+	return strings.HasPrefix(strings.ToLower(mimeType), "audio/")
+}
+
+func getWebAudioPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".web", relativePath, ".m4a")
+}
+
+// ExtractAudioMetadata reads duration, codec and tags from an audio file
+// using ffprobe. Ogg files (Opus) keep their tags on the stream rather than
+// the container, so both are read.
+func ExtractAudioMetadata(filePath string) (*AudioMetadata, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		filePath,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	meta := &AudioMetadata{}
+	meta.DurationSec, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	meta.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	tags := map[string]string{}
+	for _, s := range probe.Streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		meta.Codec = s.CodecName
+		for k, v := range lowerKeys(s.Tags) {
+			tags[k] = v
+		}
+		break
+	}
+	if meta.Codec == "" {
+		return nil, fmt.Errorf("no audio stream")
+	}
+	for k, v := range lowerKeys(probe.Format.Tags) {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+
+	meta.Title = tags["title"]
+	meta.Artist = tags["artist"]
+	meta.Album = tags["album"]
+
+	// "date" is often just a release year, which parseVideoTime rejects
+	for _, key := range []string{"creation_time", "date"} {
+		if t, ok := parseVideoTime(tags[key]); ok {
+			meta.TakenAt = &t
+			break
+		}
+	}
+
+	return meta, nil
+}
+
+// GenerateWaveform draws an audio file's waveform and saves it, scaled down,
+// as the item's thumbnail
+func GenerateWaveform(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	tempDir, err := os.MkdirTemp("", "waveform-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// showwavespic leaves the background transparent, which JPEG can't keep
+	wavePath := filepath.Join(tempDir, "waveform.jpg")
+	filter := fmt.Sprintf(
+		"[0:a:0]aformat=channel_layouts=mono,showwavespic=s=%[1]dx%[2]d:colors=%[3]s[w];"+
+			"color=c=white:s=%[1]dx%[2]d[bg];[bg][w]overlay=format=auto:shortest=1",
+		WaveformWidth, WaveformHeight, WaveformColor,
+	)
+	cmd := exec.Command("ffmpeg",
+		"-y", "-i", srcPath,
+		"-filter_complex", filter,
+		"-frames:v", "1", "-q:v", "2",
+		wavePath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+
+	return GenerateImageThumbnail(wavePath, originalRelPath)
+}
+
+// needsWebAudio reports whether an audio file should get a web rendition:
+// browsers can't all play it, or it's too large to stream comfortably
+func needsWebAudio(meta *AudioMetadata) bool {
+	if meta.Codec != "aac" && meta.Codec != "mp3" {
+		return true
+	}
+	return meta.Bitrate > webAudioMaxBitrate
+}
+
+// GenerateWebAudio transcodes an audio file to AAC in an M4A container with
+// the moov atom up front (faststart) for streaming
+func GenerateWebAudio(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	fullPath, relPath = getWebAudioPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create web directory: %w", err)
+	}
+
+	tempPath := fullPath + ".temp.m4a"
+	defer os.Remove(tempPath)
+
+	cmd := exec.Command("ffmpeg",
+		"-y", "-i", srcPath,
+		"-map", "0:a:0",
+		"-c:a", "aac",
+		"-b:a", WebAudioBitrate,
+		"-ac", "2",
+		"-movflags", "+faststart",
+		"-map_metadata", "-1",
+		tempPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		return "", "", fmt.Errorf("failed to save web audio: %w", err)
+	}
+
+	return fullPath, relPath, nil
+}
+
+func processAudioFile(result *SavedFile, fullPath, relativePath string) {
+	if meta, err := ExtractAudioMetadata(fullPath); err == nil {
+		result.Audio = meta
+	} else {
+		fmt.Printf("Warning: failed to extract audio metadata: %v\n", err)
+	}
+
+	if thumbFull, thumbRel, err := GenerateWaveform(fullPath, relativePath); err == nil {
+		result.ThumbnailFullPath = thumbFull
+		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
+	} else {
+		fmt.Printf("Warning: failed to generate waveform: %v\n", err)
+	}
+
+	// Without a web version, Download serves the original
+	if result.Audio != nil && needsWebAudio(result.Audio) {
+		if webFull, webRel, err := GenerateWebAudio(fullPath, relativePath); err == nil {
+			result.WebFullPath = webFull
+			result.WebRelPath = webRel
+		} else {
+			fmt.Printf("Warning: failed to generate web audio: %v\n", err)
+		}
+	}
+}
+
+func populateItemAudio(item *models.MediaItem, meta *AudioMetadata) {
+	item.DurationSec = int(math.Round(meta.DurationSec))
+	item.AudioCodec = meta.Codec
+	item.Bitrate = meta.Bitrate
+	item.Title = meta.Title
+	item.Artist = meta.Artist
+	item.Album = meta.Album
+	item.TakenAt = meta.TakenAt
+}
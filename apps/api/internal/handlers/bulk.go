@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// MaxBulkItems limits how many items a single bulk request can touch
+const MaxBulkItems = 500
+
+// Bulk actions
+const (
+	BulkActionDelete     = "delete"     // Move to trash
+	BulkActionRestore    = "restore"    // Restore from trash
+	BulkActionPurge      = "purge"      // Permanently delete trashed items
+	BulkActionArchive    = "archive"    // Hide from the main timeline
+	BulkActionUnarchive  = "unarchive"  // Show in the main timeline again
+	BulkActionSetPrivacy = "setPrivacy" // Requires isPrivate
+	BulkActionSetTakenAt = "setTakenAt" // Requires takenAt
+	BulkActionDownload   = "download"   // Returns signed download URLs
+)
+
+// Per-item bulk result statuses
+const (
+	bulkStatusOK        = "ok"
+	bulkStatusNotFound  = "not_found"
+	bulkStatusForbidden = "forbidden"
+	bulkStatusConflict  = "conflict"
+)
+
+// bulkRequest is the request body for POST /media/bulk
+type bulkRequest struct {
+	Action    string      `json:"action"`
+	IDs       []uuid.UUID `json:"ids"`
+	IsPrivate *bool       `json:"isPrivate,omitempty"`
+	TakenAt   *time.Time  `json:"takenAt,omitempty"`
+}
+
+// bulkResult is the outcome of a bulk action for one item
+type bulkResult struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	URL    string    `json:"url,omitempty"`
+}
+
+// validate checks the action and its parameters
+func (req *bulkRequest) validate() error {
+	if len(req.IDs) == 0 || len(req.IDs) > MaxBulkItems {
+		return fmt.Errorf("ids must contain between 1 and %d items", MaxBulkItems)
+	}
+
+	switch req.Action {
+	case BulkActionDelete, BulkActionRestore, BulkActionPurge,
+		BulkActionArchive, BulkActionUnarchive, BulkActionDownload:
+	case BulkActionSetPrivacy:
+		if req.IsPrivate == nil {
+			return errors.New("isPrivate is required for setPrivacy")
+		}
+	case BulkActionSetTakenAt:
+		if req.TakenAt == nil {
+			return errors.New("takenAt is required for setTakenAt")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", req.Action)
+	}
+	return nil
+}
+
+// Bulk handles POST /media/bulk
+// Applies one action to many items. Permissions are checked per item, all
+// changes run in a single transaction, and files of purged items are only
+// removed from disk after the transaction commits.
+func (h *MediaHandler) Bulk(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser := h.getCurrentUser(r)
+	if currentUser == nil || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return
+	}
+
+	var req bulkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ids := uniqueIDs(req.IDs)
+	items, err := h.svc.GetByIDsIncludingTrash(r.Context(), ids)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+
+	byID := make(map[uuid.UUID]*models.MediaItem, len(items))
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	results := make([]bulkResult, len(ids))
+	var purged []*models.MediaItem
+
+	err = h.svc.Transaction(r.Context(), func(tx *service.MediaService) error {
+		for i, id := range ids {
+			item := byID[id]
+			result, err := applyBulkAction(r.Context(), tx, h.signer, currentUser, item, &req)
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+			result.ID = id
+			results[i] = result
+			if req.Action == BulkActionPurge && result.Status == bulkStatusOK {
+				purged = append(purged, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("bulk %s failed, no changes were made: %v", req.Action, err),
+		})
+		return
+	}
+
+	// Rows are gone for good; now it is safe to remove the files
+	for _, item := range purged {
+		deleteMediaFiles(item)
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Status == bulkStatusOK {
+			succeeded++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"action":    req.Action,
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// applyBulkAction checks permissions and applies the action to a single item.
+// Item-level problems are reported in the result; a returned error aborts the
+// whole transaction. Download URLs are signed so browsers can use them as-is.
+func applyBulkAction(ctx context.Context, tx *service.MediaService, signer *service.MediaURLSigner, u *models.User, item *models.MediaItem, req *bulkRequest) (bulkResult, error) {
+	if item == nil || !canView(u, item) {
+		return bulkResult{Status: bulkStatusNotFound}, nil
+	}
+
+	trashed := item.DeletedAt.Valid
+	switch req.Action {
+	case BulkActionRestore, BulkActionPurge:
+		if !trashed {
+			return bulkResult{Status: bulkStatusConflict, Error: "item is not in the trash"}, nil
+		}
+	default:
+		if trashed {
+			return bulkResult{Status: bulkStatusNotFound}, nil
+		}
+	}
+
+	if req.Action == BulkActionDownload {
+		return bulkResult{Status: bulkStatusOK, URL: signer.URLs(item).Original}, nil
+	}
+
+	if !canManage(u, item.UploaderID) {
+		return bulkResult{Status: bulkStatusForbidden}, nil
+	}
+
+	var err error
+	switch req.Action {
+	case BulkActionDelete:
+		err = tx.Delete(ctx, item.ID)
+	case BulkActionRestore:
+		err = tx.Restore(ctx, item.ID)
+	case BulkActionPurge:
+		var ok bool
+		if ok, err = tx.Purge(ctx, item.ID); err == nil && !ok {
+			err = models.ErrNotFound
+		}
+	case BulkActionArchive:
+		_, err = tx.SetArchived(ctx, []uuid.UUID{item.ID}, true)
+	case BulkActionUnarchive:
+		_, err = tx.SetArchived(ctx, []uuid.UUID{item.ID}, false)
+	case BulkActionSetPrivacy:
+		err = tx.SetPrivate(ctx, item.ID, *req.IsPrivate)
+	case BulkActionSetTakenAt:
+		err = tx.SetTakenAt(ctx, item.ID, *req.TakenAt)
+	}
+
+	if errors.Is(err, models.ErrNotFound) {
+		// Changed concurrently since it was loaded
+		return bulkResult{Status: bulkStatusNotFound}, nil
+	}
+	if err != nil {
+		return bulkResult{}, err
+	}
+	return bulkResult{Status: bulkStatusOK}, nil
+}
+
+// uniqueIDs returns ids without duplicates, preserving order
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// Embedded ICC profiles are honored by converting pixels to sRGB before any
+// derivative is generated. Derivatives are written without a profile, which
+// every viewer treats as sRGB.
+//
+// Only matrix/TRC RGB profiles are supported. That covers Display P3 (iPhone),
+// Adobe RGB and the sRGB variants cameras embed; images with other profiles
+// (LUT-based, CMYK, grayscale) are left unconverted.
+
+// maxICCProfileSize guards against corrupt length fields
+const maxICCProfileSize = 4 << 20
+
+// srgbFromXYZ converts D50 XYZ (the ICC connection space) to linear sRGB.
+// It is the inverse of the Bradford-adapted sRGB primaries matrix.
+var srgbFromXYZ = invert3x3([3][3]float64{
+	{0.4360747, 0.3850649, 0.1430804},
+	{0.2225045, 0.7168786, 0.0606169},
+	{0.0139322, 0.0971045, 0.7141733},
+})
+
+// openImageSRGB opens an image with EXIF orientation applied and converts it
+// to sRGB if it carries a supported ICC profile.
+func openImageSRGB(path string) (image.Image, error) {
+	img, err := openImage(path, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readICCProfile(path)
+	if err != nil || data == nil {
+		return img, nil
+	}
+
+	profile, err := parseICCProfile(data)
+	if err != nil || profile.isSRGB() {
+		return img, nil
+	}
+
+	return profile.toSRGB(img), nil
+}
+
+// iccProfile is a parsed matrix/TRC RGB profile
+type iccProfile struct {
+	toXYZ [3][3]float64            // Linear RGB to D50 XYZ; columns are the rXYZ, gXYZ, bXYZ tags
+	trc   [3]func(float64) float64 // Per-channel tone curves, encoded to linear
+}
+
+// isSRGB reports whether the profile's primaries match sRGB closely enough
+// that conversion would not visibly change the image.
+func (p *iccProfile) isSRGB() bool {
+	srgb := invert3x3(srgbFromXYZ)
+	for i := range 3 {
+		for j := range 3 {
+			if math.Abs(p.toXYZ[i][j]-srgb[i][j]) > 0.002 {
+				return false
+			}
+		}
+	}
+	// Primaries match; also require an sRGB-like curve at mid-gray
+	return math.Abs(p.trc[0](0.5)-srgbToLinear(128)) < 0.01
+}
+
+// toSRGB converts every pixel of img from the profile's color space to sRGB
+func (p *iccProfile) toSRGB(img image.Image) *image.NRGBA {
+	dst := imaging.Clone(img)
+
+	// Decode 8-bit channel values to linear light once per channel
+	var linear [3][256]float64
+	for c := range 3 {
+		for v := range 256 {
+			linear[c][v] = p.trc[c](float64(v) / 255)
+		}
+	}
+
+	// Encode linear light back to 8-bit sRGB through a fine-grained table
+	const encodeSteps = 4096
+	var encode [encodeSteps + 1]uint8
+	for i := range encode {
+		encode[i] = uint8(linearToSRGB(float64(i) / encodeSteps))
+	}
+
+	m := mul3x3(srgbFromXYZ, p.toXYZ)
+	for i := 0; i+3 < len(dst.Pix); i += 4 {
+		r := linear[0][dst.Pix[i]]
+		g := linear[1][dst.Pix[i+1]]
+		b := linear[2][dst.Pix[i+2]]
+		for c := range 3 {
+			v := m[c][0]*r + m[c][1]*g + m[c][2]*b
+			v = max(0, min(1, v))
+			dst.Pix[i+c] = encode[int(v*encodeSteps+0.5)]
+		}
+	}
+	return dst
+}
+
+// parseICCProfile reads the primaries and tone curves of an RGB matrix/TRC profile
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, errors.New("not an ICC profile")
+	}
+	if string(data[16:20]) != "RGB " {
+		return nil, errors.New("not an RGB profile")
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := range count {
+		entry := 132 + i*12
+		if entry+12 > len(data) {
+			return nil, errors.New("truncated tag table")
+		}
+		sig := string(data[entry : entry+4])
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		size := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("tag %q out of range", sig)
+		}
+		tags[sig] = data[offset : offset+size]
+	}
+
+	p := &iccProfile{}
+	for c, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseXYZTag(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sig, err)
+		}
+		for row := range 3 {
+			p.toXYZ[row][c] = xyz[row]
+		}
+	}
+	for c, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseCurveTag(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sig, err)
+		}
+		p.trc[c] = curve
+	}
+	return p, nil
+}
+
+// s15Fixed16 decodes an ICC signed 15.16 fixed-point number
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func parseXYZTag(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, errors.New("missing or invalid XYZ tag")
+	}
+	return [3]float64{s15Fixed16(tag[8:]), s15Fixed16(tag[12:]), s15Fixed16(tag[16:])}, nil
+}
+
+// parseCurveTag decodes a 'curv' or 'para' tone curve into a function mapping
+// encoded values in [0, 1] to linear light.
+func parseCurveTag(tag []byte) (func(float64) float64, error) {
+	if len(tag) < 12 {
+		return nil, errors.New("missing or invalid curve tag")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if len(tag) < 12+2*n {
+			return nil, errors.New("truncated curve")
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+		}
+		return func(x float64) float64 {
+			pos := x * float64(n-1)
+			i := min(int(pos), n-2)
+			frac := pos - float64(i)
+			return table[i]*(1-frac) + table[i+1]*frac
+		}, nil
+
+	case "para":
+		fn := binary.BigEndian.Uint16(tag[8:10])
+		nparams := map[uint16]int{0: 1, 1: 3, 2: 4, 3: 5, 4: 7}[fn]
+		if nparams == 0 || len(tag) < 12+4*nparams {
+			return nil, fmt.Errorf("unsupported parametric curve type %d", fn)
+		}
+		var p [7]float64
+		for i := range nparams {
+			p[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		switch fn {
+		case 0:
+			return func(x float64) float64 { return math.Pow(x, g) }, nil
+		case 1:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return math.Pow(a*x+b, g)
+				}
+				return 0
+			}, nil
+		case 2:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return math.Pow(a*x+b, g) + c
+				}
+				return c
+			}, nil
+		case 3:
+			return func(x float64) float64 {
+				if x >= d {
+					return math.Pow(a*x+b, g)
+				}
+				return c * x
+			}, nil
+		default:
+			return func(x float64) float64 {
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported curve type %q", tag[:4])
+}
+
+// readICCProfile returns the embedded ICC profile of a JPEG, PNG or WebP file,
+// or nil if there is none.
+func readICCProfile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(12)
+	if err != nil {
+		return nil, nil
+	}
+
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		return readJPEGICCProfile(r)
+	case bytes.HasPrefix(magic, []byte("\x89PNG\r\n\x1a\n")):
+		return readPNGICCProfile(r)
+	case string(magic[:4]) == "RIFF" && string(magic[8:12]) == "WEBP":
+		return readWebPICCProfile(r)
+	}
+	return nil, nil
+}
+
+// readJPEGICCProfile reassembles the profile from its APP2 "ICC_PROFILE"
+// segments, which may be split across several markers.
+func readJPEGICCProfile(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(2); err != nil {
+		return nil, err
+	}
+
+	chunks := make(map[byte][]byte)
+	var total, count int
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xFF {
+			return nil, errors.New("invalid JPEG marker")
+		}
+		// Profiles always come before the image data
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			break
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length < 2 {
+			return nil, errors.New("invalid JPEG segment length")
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, err
+		}
+
+		const iccHeader = "ICC_PROFILE\x00"
+		if marker[1] == 0xE2 && len(segment) > len(iccHeader)+2 && string(segment[:len(iccHeader)]) == iccHeader {
+			seq := segment[len(iccHeader)]
+			count = int(segment[len(iccHeader)+1])
+			chunks[seq] = segment[len(iccHeader)+2:]
+			total += len(chunks[seq])
+			if total > maxICCProfileSize {
+				return nil, errors.New("ICC profile too large")
+			}
+		}
+	}
+
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	// Every segment carries the total count; a profile missing a part is unusable
+	var profile []byte
+	for seq := 1; seq <= max(count, len(chunks)); seq++ {
+		chunk, ok := chunks[byte(seq)]
+		if !ok {
+			return nil, errors.New("incomplete ICC profile")
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile, nil
+}
+
+// readPNGICCProfile returns the zlib-compressed profile from the iCCP chunk
+func readPNGICCProfile(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(8); err != nil {
+		return nil, err
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		typ := string(header[4:8])
+
+		switch typ {
+		case "iCCP":
+			if length > maxICCProfileSize {
+				return nil, errors.New("ICC profile too large")
+			}
+			chunk := make([]byte, length)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, err
+			}
+			// Profile name, NUL, compression method (always zlib), data
+			nul := bytes.IndexByte(chunk, 0)
+			if nul < 0 || nul+2 > len(chunk) {
+				return nil, errors.New("invalid iCCP chunk")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[nul+2:]))
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return io.ReadAll(io.LimitReader(zr, maxICCProfileSize))
+		case "IDAT", "IEND":
+			return nil, nil
+		}
+
+		// Skip chunk data and CRC
+		if _, err := r.Discard(int(length) + 4); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readWebPICCProfile returns the profile from the ICCP chunk of an extended WebP
+func readWebPICCProfile(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(12); err != nil {
+		return nil, err
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		typ := string(header[:4])
+		length := binary.LittleEndian.Uint32(header[4:8])
+		padded := int(length) + int(length&1)
+
+		switch typ {
+		case "ICCP":
+			if length > maxICCProfileSize {
+				return nil, errors.New("ICC profile too large")
+			}
+			profile := make([]byte, length)
+			if _, err := io.ReadFull(r, profile); err != nil {
+				return nil, err
+			}
+			return profile, nil
+		case "VP8 ", "VP8L", "ANIM":
+			return nil, nil
+		}
+
+		if _, err := r.Discard(padded); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func mul3x3(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				m[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return m
+}
+
+func invert3x3(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	return [3][3]float64{
+		{
+			(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det,
+			(m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det,
+			(m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det,
+		},
+		{
+			(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det,
+			(m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det,
+			(m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det,
+		},
+		{
+			(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det,
+			(m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det,
+			(m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det,
+		},
+	}
+}
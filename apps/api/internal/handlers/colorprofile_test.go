@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// The fixtures in testdata are 32×32 images of four 16×16 patches tagged with
+// Apple's Display P3 profile. Expected values are the patches converted with
+// the standard D65 P3-to-sRGB matrix, independently of the ICC path.
+var p3Patches = []struct {
+	x, y   int
+	p3     [3]uint8
+	expect [3]uint8
+}{
+	{8, 8, [3]uint8{128, 128, 128}, [3]uint8{128, 128, 128}},
+	{24, 8, [3]uint8{200, 120, 80}, [3]uint8{213, 115, 70}},
+	{8, 24, [3]uint8{90, 110, 210}, [3]uint8{85, 111, 217}},
+	{24, 24, [3]uint8{120, 170, 100}, [3]uint8{104, 172, 91}},
+}
+
+// Display P3 primaries (D50-adapted) and sRGB transfer curve
+var (
+	displayP3Primaries = [3][3]float64{
+		{0.515102, 0.241196, -0.001053},
+		{0.291965, 0.692245, 0.041887},
+		{0.157153, 0.066561, 0.784405},
+	}
+	srgbPrimaries = [3][3]float64{
+		{0.4360747, 0.2225045, 0.0139322},
+		{0.3850649, 0.7168786, 0.0971045},
+		{0.1430804, 0.0606169, 0.7141733},
+	}
+	srgbCurve = paraTag(3, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)
+)
+
+func TestOpenImageSRGBConvertsDisplayP3(t *testing.T) {
+	for _, tc := range []struct {
+		file      string
+		tolerance int
+	}{
+		{"display-p3.png", 1},
+		{"display-p3.jpg", 4}, // JPEG rounding is amplified by the conversion
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tc.file)
+			original, err := imaging.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			converted, err := openImageSRGB(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range p3Patches {
+				assertPixel(t, original, p.x, p.y, p.p3, tc.tolerance)
+				assertPixel(t, converted, p.x, p.y, p.expect, tc.tolerance)
+			}
+		})
+	}
+}
+
+func TestOpenImageSRGBLeavesSRGBUnchanged(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "display-p3.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "srgb.png")
+	writeFile(t, path, withPNGProfile(t, stripPNGProfile(t, data), testICCProfile(srgbPrimaries, srgbCurve)))
+
+	profile, err := readICCProfile(path)
+	if err != nil || profile == nil {
+		t.Fatalf("readICCProfile = %v, %v; want the sRGB profile", profile, err)
+	}
+	want, err := imaging.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := openImageSRGB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(imaging.Clone(got).Pix, imaging.Clone(want).Pix) {
+		t.Error("sRGB-tagged image was modified")
+	}
+}
+
+func TestReadICCProfile(t *testing.T) {
+	want := testICCProfile(displayP3Primaries, srgbCurve)
+
+	for _, file := range []string{"display-p3.jpg", "display-p3.png"} {
+		got, err := readICCProfile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: profile differs from Display P3", file)
+		}
+	}
+
+	t.Run("split across APP2 segments", func(t *testing.T) {
+		jpegData, err := os.ReadFile(filepath.Join("testdata", "display-p3.jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Replace the single segment with three, stored out of order
+		third := len(want) / 3
+		chunks := [][]byte{want[:third], want[third : 2*third], want[2*third:]}
+		var segments []byte
+		for _, seq := range []int{2, 1, 3} {
+			segments = append(segments, app2Segment(chunks[seq-1], seq, len(chunks))...)
+		}
+		single := app2Segment(want, 1, 1)
+		split := bytes.Replace(jpegData, single, segments, 1)
+		if bytes.Equal(split, jpegData) {
+			t.Fatal("fixture has no single APP2 ICC segment")
+		}
+
+		path := filepath.Join(t.TempDir(), "split.jpg")
+		writeFile(t, path, split)
+		got, err := readICCProfile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error("reassembled profile differs")
+		}
+
+		img, err := openImageSRGB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range p3Patches {
+			assertPixel(t, img, p.x, p.y, p.expect, 4)
+		}
+	})
+
+	t.Run("missing APP2 segment", func(t *testing.T) {
+		jpegData, err := os.ReadFile(filepath.Join("testdata", "display-p3.jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		half := len(want) / 2
+		partial := bytes.Replace(jpegData, app2Segment(want, 1, 1), app2Segment(want[:half], 1, 2), 1)
+		path := filepath.Join(t.TempDir(), "partial.jpg")
+		writeFile(t, path, partial)
+		if _, err := readICCProfile(path); err == nil {
+			t.Error("expected an error for an incomplete profile")
+		}
+	})
+
+	t.Run("WebP ICCP", func(t *testing.T) {
+		var body []byte
+		body = append(body, "WEBP"...)
+		body = append(body, riffChunk("VP8X", make([]byte, 10))...)
+		body = append(body, riffChunk("ICCP", want)...)
+		body = append(body, riffChunk("VP8L", make([]byte, 5))...)
+		webp := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+		webp = append(webp, body...)
+
+		path := filepath.Join(t.TempDir(), "p3.webp")
+		writeFile(t, path, webp)
+		got, err := readICCProfile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error("WebP profile differs")
+		}
+	})
+
+	t.Run("untagged", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "display-p3.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "untagged.png")
+		writeFile(t, path, stripPNGProfile(t, data))
+		if got, err := readICCProfile(path); got != nil || err != nil {
+			t.Errorf("readICCProfile = %d bytes, %v; want none", len(got), err)
+		}
+	})
+}
+
+func TestParseICCProfile(t *testing.T) {
+	p, err := parseICCProfile(testICCProfile(displayP3Primaries, srgbCurve))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row := range 3 {
+		for col := range 3 {
+			if math.Abs(p.toXYZ[row][col]-displayP3Primaries[col][row]) > 1e-4 {
+				t.Errorf("toXYZ[%d][%d] = %f, want %f", row, col, p.toXYZ[row][col], displayP3Primaries[col][row])
+			}
+		}
+	}
+	if p.isSRGB() {
+		t.Error("Display P3 reported as sRGB")
+	}
+
+	srgb, err := parseICCProfile(testICCProfile(srgbPrimaries, srgbCurve))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srgb.isSRGB() {
+		t.Error("sRGB profile not recognized")
+	}
+	// Same primaries with a gamma 1.8 curve need converting
+	gamma18, err := parseICCProfile(testICCProfile(srgbPrimaries, curvTag(461)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gamma18.isSRGB() {
+		t.Error("gamma 1.8 profile reported as sRGB")
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not ICC":   make([]byte, 200),
+		"truncated": testICCProfile(displayP3Primaries, srgbCurve)[:140],
+	} {
+		if _, err := parseICCProfile(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseCurveTag(t *testing.T) {
+	srgbDecode := func(x float64) float64 {
+		if x <= 0.04045 {
+			return x / 12.92
+		}
+		return math.Pow((x+0.055)/1.055, 2.4)
+	}
+
+	tests := []struct {
+		name string
+		tag  []byte
+		want func(float64) float64
+	}{
+		{"curv identity", curvTag(), func(x float64) float64 { return x }},
+		{"curv gamma", curvTag(563), func(x float64) float64 { return math.Pow(x, 563.0/256) }},
+		{"curv table", curvTag(0, 16384, 65535), func(x float64) float64 {
+			if x < 0.5 {
+				return x / 2
+			}
+			return 0.25 + (x-0.5)*1.5
+		}},
+		{"para 0", paraTag(0, 2.2), func(x float64) float64 { return math.Pow(x, 2.2) }},
+		{"para 1", paraTag(1, 2.2, 1.2, -0.1), func(x float64) float64 {
+			if x < 0.1/1.2 {
+				return 0
+			}
+			return math.Pow(1.2*x-0.1, 2.2)
+		}},
+		{"para 2", paraTag(2, 2.2, 1.2, -0.1, 0.05), func(x float64) float64 {
+			if x < 0.1/1.2 {
+				return 0.05
+			}
+			return math.Pow(1.2*x-0.1, 2.2) + 0.05
+		}},
+		{"para 3", srgbCurve, srgbDecode},
+		{"para 4", paraTag(4, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045, 0.01, 0.02), func(x float64) float64 {
+			if x < 0.04045 {
+				return x/12.92 + 0.02
+			}
+			return srgbDecode(x) + 0.01
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			curve, err := parseCurveTag(tc.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, x := range []float64{0, 0.02, 0.1, 0.25, 0.5, 0.75, 1} {
+				// s15Fixed16 parameters are accurate to about 1e-5
+				if got, want := curve(x), tc.want(x); math.Abs(got-want) > 1e-4 {
+					t.Errorf("curve(%g) = %f, want %f", x, got, want)
+				}
+			}
+		})
+	}
+
+	for name, tag := range map[string][]byte{
+		"para 5":         paraTag(5, 1, 1, 1, 1, 1, 1, 1),
+		"truncated curv": curvTag(0, 65535)[:14],
+		"truncated para": paraTag(3, 2.4, 1, 0, 1, 0)[:20],
+		"unknown type":   append([]byte("sf32"), make([]byte, 12)...),
+		"too short":      []byte("curv"),
+	} {
+		if _, err := parseCurveTag(tag); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func assertPixel(t *testing.T, img image.Image, x, y int, want [3]uint8, tolerance int) {
+	t.Helper()
+	c := imaging.Clone(img).NRGBAAt(x, y)
+	got := [3]uint8{c.R, c.G, c.B}
+	for i := range 3 {
+		if diff := int(got[i]) - int(want[i]); diff > tolerance || diff < -tolerance {
+			t.Errorf("pixel (%d, %d) = %v, want %v ±%d", x, y, got, want, tolerance)
+			return
+		}
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testICCProfile builds a minimal matrix/TRC RGB display profile. primaries
+// holds the rXYZ, gXYZ and bXYZ values; all three channels share curve.
+func testICCProfile(primaries [3][3]float64, curve []byte) []byte {
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"rXYZ", xyzTag(primaries[0])},
+		{"gXYZ", xyzTag(primaries[1])},
+		{"bXYZ", xyzTag(primaries[2])},
+		{"rTRC", curve}, {"gTRC", curve}, {"bTRC", curve},
+	}
+
+	offset := 128 + 4 + 12*len(tags)
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	var data []byte
+	for _, tag := range tags {
+		table = append(table, tag.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(data)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+		data = append(data, tag.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header, uint32(128+len(table)+len(data)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntrRGB XYZ ")
+	copy(header[36:], "acsp")
+	return append(append(header, table...), data...)
+}
+
+func fixed16(v float64) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536))))
+}
+
+func xyzTag(xyz [3]float64) []byte {
+	tag := []byte("XYZ \x00\x00\x00\x00")
+	for _, v := range xyz {
+		tag = append(tag, fixed16(v)...)
+	}
+	return tag
+}
+
+func curvTag(entries ...uint16) []byte {
+	tag := []byte("curv\x00\x00\x00\x00")
+	tag = binary.BigEndian.AppendUint32(tag, uint32(len(entries)))
+	for _, e := range entries {
+		tag = binary.BigEndian.AppendUint16(tag, e)
+	}
+	return tag
+}
+
+func paraTag(fn uint16, params ...float64) []byte {
+	tag := []byte("para\x00\x00\x00\x00")
+	tag = binary.BigEndian.AppendUint16(tag, fn)
+	tag = append(tag, 0, 0)
+	for _, p := range params {
+		tag = append(tag, fixed16(p)...)
+	}
+	return tag
+}
+
+func app2Segment(chunk []byte, seq, total int) []byte {
+	payload := append([]byte("ICC_PROFILE\x00"), byte(seq), byte(total))
+	payload = append(payload, chunk...)
+	segment := []byte{0xFF, 0xE2}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func riffChunk(typ string, data []byte) []byte {
+	chunk := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// pngChunks splits a PNG into its chunks, each with length, type and CRC
+func pngChunks(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var chunks [][]byte
+	for i := 8; i < len(data); {
+		if i+8 > len(data) {
+			t.Fatal("truncated PNG")
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		chunks = append(chunks, data[i:end])
+		i = end
+	}
+	return chunks
+}
+
+func stripPNGProfile(t *testing.T, data []byte) []byte {
+	t.Helper()
+	out := append([]byte{}, data[:8]...)
+	for _, chunk := range pngChunks(t, data) {
+		if string(chunk[4:8]) != "iCCP" {
+			out = append(out, chunk...)
+		}
+	}
+	return out
+}
+
+// withPNGProfile inserts an iCCP chunk after IHDR
+func withPNGProfile(t *testing.T, data, profile []byte) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(profile)
+	zw.Close()
+
+	body := append([]byte("iCCPtest\x00\x00"), compressed.Bytes()...)
+	iccp := binary.BigEndian.AppendUint32(nil, uint32(len(body)-4))
+	iccp = append(iccp, body...)
+	iccp = binary.BigEndian.AppendUint32(iccp, crc32.ChecksumIEEE(body))
+
+	out := append([]byte{}, data[:8]...)
+	for _, chunk := range pngChunks(t, data) {
+		out = append(out, chunk...)
+		if string(chunk[4:8]) == "IHDR" {
+			out = append(out, iccp...)
+		}
+	}
+	return out
+}
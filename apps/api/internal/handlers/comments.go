@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/google/uuid"
+)
+
+type CommentHandler struct {
+	svc      *service.CommentService
+	mediaSvc *service.MediaService
+	userSvc  *service.UserService
+}
+
+func NewCommentHandler(svc *service.CommentService, mediaSvc *service.MediaService, userSvc *service.UserService) *CommentHandler {
+	return &CommentHandler{svc: svc, mediaSvc: mediaSvc, userSvc: userSvc}
+}
+
+// createCommentRequest is the request body for POST /media/{id}/comments
+type createCommentRequest struct {
+	Body     string     `json:"body"`
+	ParentID *uuid.UUID `json:"parentId,omitempty"`
+}
+
+// updateCommentRequest is the request body for PUT /media/{id}/comments/{commentId}
+type updateCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// reactionRequest is the request body for POST /media/{id}/reactions
+type reactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// getComment fetches the {commentId} comment and checks it belongs to the item.
+// Returns the comment, or writes an error response and returns nil.
+func (h *CommentHandler) getComment(w http.ResponseWriter, r *http.Request, item *models.MediaItem) *models.Comment {
+	commentID, ok := parseUUIDParam(w, r, "commentId")
+	if !ok {
+		return nil
+	}
+
+	comment, err := h.svc.GetByID(r.Context(), commentID)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get comment: %v", err),
+		})
+		return nil
+	}
+	if err != nil || comment.MediaID != item.ID {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "comment not found",
+		})
+		return nil
+	}
+	return comment
+}
+
+// writeCommentError writes the response for a failed comment or reaction change
+func writeCommentError(w http.ResponseWriter, err error, action string) {
+	if errors.Is(err, models.ErrInvalidInput) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]any{
+		"error": fmt.Sprintf("failed to %s: %v", action, err),
+	})
+}
+
+// List handles GET /media/{id}/comments
+// Returns all comments oldest first; clients build threads from parentId.
+func (h *CommentHandler) List(w http.ResponseWriter, r *http.Request) {
+	_, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	comments, err := h.svc.ListByMedia(r.Context(), item.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list comments: %v", err),
+		})
+		return
+	}
+
+	if comments == nil {
+		comments = []models.Comment{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"comments": comments})
+}
+
+// Create handles POST /media/{id}/comments
+func (h *CommentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	var req createCommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	comment := &models.Comment{
+		MediaID:  item.ID,
+		ParentID: req.ParentID,
+		AuthorID: &currentUser.ID,
+		Body:     req.Body,
+	}
+
+	if err := h.svc.Create(r.Context(), comment); err != nil {
+		writeCommentError(w, err, "save comment")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{"comment": comment})
+}
+
+// Update handles PUT /media/{id}/comments/{commentId}
+// Only the author can edit a comment.
+func (h *CommentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	comment := h.getComment(w, r, item)
+	if comment == nil {
+		return
+	}
+
+	if comment.AuthorID == nil || *comment.AuthorID != currentUser.ID {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "only the author can edit this comment",
+		})
+		return
+	}
+
+	var req updateCommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.svc.UpdateBody(r.Context(), comment, req.Body); err != nil {
+		writeCommentError(w, err, "update comment")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"comment": comment})
+}
+
+// Delete handles DELETE /media/{id}/comments/{commentId}
+// The author or a household admin (moderation) can delete a comment.
+// Replies to the comment are deleted with it.
+func (h *CommentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	comment := h.getComment(w, r, item)
+	if comment == nil {
+		return
+	}
+
+	if !canManage(currentUser, comment.AuthorID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "not allowed to delete this comment",
+		})
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), comment.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to delete comment: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "deleted successfully",
+	})
+}
+
+// ListReactions handles GET /media/{id}/reactions
+func (h *CommentHandler) ListReactions(w http.ResponseWriter, r *http.Request) {
+	_, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	h.writeReactions(w, r, item)
+}
+
+// AddReaction handles POST /media/{id}/reactions
+func (h *CommentHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	var req reactionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.svc.AddReaction(r.Context(), item.ID, currentUser.ID, req.Emoji); err != nil {
+		writeCommentError(w, err, "save reaction")
+		return
+	}
+
+	h.writeReactions(w, r, item)
+}
+
+// RemoveReaction handles DELETE /media/{id}/reactions?emoji=...
+func (h *CommentHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := resolveViewableItem(w, r, h.mediaSvc, h.userSvc)
+	if item == nil {
+		return
+	}
+
+	emoji := r.URL.Query().Get("emoji")
+	if emoji == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "missing emoji parameter",
+		})
+		return
+	}
+
+	if err := h.svc.RemoveReaction(r.Context(), item.ID, currentUser.ID, emoji); err != nil {
+		writeCommentError(w, err, "remove reaction")
+		return
+	}
+
+	h.writeReactions(w, r, item)
+}
+
+// writeReactions responds with the item's reactions grouped by emoji
+func (h *CommentHandler) writeReactions(w http.ResponseWriter, r *http.Request, item *models.MediaItem) {
+	summary, err := h.svc.ReactionSummary(r.Context(), item.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list reactions: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"reactions": summary})
+}
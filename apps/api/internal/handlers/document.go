@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// DocumentPageSize is the long side of the first-page render that thumbnails
+// are made from
+const DocumentPageSize = 1200
+
+// MaxDocumentTextBytes caps the text stored for search per document
+const MaxDocumentTextBytes = 1 << 20
+
+// DocumentConvertTimeout bounds converting an office file to PDF
+const DocumentConvertTimeout = 2 * time.Minute
+
+// documentMimeTypes maps document extensions to their MIME types
+var documentMimeTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".rtf":  "application/rtf",
+}
+
+// DocumentMetadata holds what is extracted from a document
+type DocumentMetadata struct {
+	PageCount int
+	Width     int    // First page, as rendered for the thumbnail
+	Height    int    // First page, as rendered for the thumbnail
+	Text      string // Whitespace-collapsed, at most MaxDocumentTextBytes
+}
+
+// IsDocument checks if the MIME type is a supported document format
+func IsDocument(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	for _, t := range documentMimeTypes {
+		if mimeType == t {
+			return true
+		}
+	}
+	return false
+}
+
+func getDocumentPDFPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".previews", relativePath, ".pdf")
+}
+
+// ConvertDocumentToPDF converts an office file to a PDF preview with
+// LibreOffice. Each conversion uses its own profile directory so that
+// concurrent uploads don't contend for LibreOffice's lock.
+func ConvertDocumentToPDF(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	fullPath, relPath = getDocumentPDFPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create preview directory: %w", err)
+	}
+
+	// Next to the preview so the result can be renamed into place
+	tempDir, err := os.MkdirTemp(filepath.Dir(fullPath), ".soffice-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DocumentConvertTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "soffice",
+		"-env:UserInstallation=file://"+filepath.Join(tempDir, "profile"),
+		"--headless",
+		"--convert-to", "pdf",
+		"--outdir", tempDir,
+		srcPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("soffice failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+
+	converted := filepath.Join(tempDir, strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))+".pdf")
+	if !fileExists(converted) {
+		return "", "", fmt.Errorf("soffice produced no PDF, output: %s", lastLines(string(output), 5))
+	}
+	if err := os.Rename(converted, fullPath); err != nil {
+		return "", "", fmt.Errorf("failed to save PDF preview: %w", err)
+	}
+
+	return fullPath, relPath, nil
+}
+
+// RenderDocumentPage renders the first page of a PDF to a JPEG at destPath
+func RenderDocumentPage(pdfPath, destPath string) error {
+	prefix := strings.TrimSuffix(destPath, filepath.Ext(destPath))
+	cmd := exec.Command("pdftoppm",
+		"-f", "1", "-l", "1", "-singlefile",
+		"-jpeg", "-jpegopt", "quality=90",
+		"-scale-to", strconv.Itoa(DocumentPageSize),
+		pdfPath, prefix,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pdftoppm failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+	if rendered := prefix + ".jpg"; rendered != destPath {
+		return os.Rename(rendered, destPath)
+	}
+	return nil
+}
+
+// ExtractDocumentMetadata reads the page count and text of a PDF
+func ExtractDocumentMetadata(pdfPath string) (*DocumentMetadata, error) {
+	output, err := exec.Command("pdfinfo", pdfPath).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("pdfinfo failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+
+	meta := &DocumentMetadata{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && key == "Pages" {
+			meta.PageCount, _ = strconv.Atoi(strings.TrimSpace(value))
+			break
+		}
+	}
+
+	// Text is optional; scanned PDFs have none
+	text, err := exec.Command("pdftotext", "-q", "-enc", "UTF-8", pdfPath, "-").Output()
+	if err != nil {
+		fmt.Printf("Warning: failed to extract document text: %v\n", err)
+	} else {
+		meta.Text = normalizeDocumentText(text)
+	}
+
+	return meta, nil
+}
+
+// normalizeDocumentText collapses whitespace and caps the text at
+// MaxDocumentTextBytes. NUL bytes and invalid UTF-8, which PostgreSQL rejects
+// in text columns, are dropped.
+func normalizeDocumentText(text []byte) string {
+	s := strings.ToValidUTF8(string(text), "")
+	s = strings.ReplaceAll(s, "\x00", "")
+	s = strings.Join(strings.Fields(s), " ")
+
+	if len(s) > MaxDocumentTextBytes {
+		cut := MaxDocumentTextBytes
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return s
+}
+
+// processDocumentFile converts office files to a PDF preview, then reads the
+// page count and text from the PDF and makes the thumbnail from its first page
+func processDocumentFile(result *SavedFile, fullPath, relativePath, mimeType string) {
+	pdfPath := fullPath
+	if !strings.EqualFold(mimeType, "application/pdf") {
+		previewFull, previewRel, err := ConvertDocumentToPDF(fullPath, relativePath)
+		if err != nil {
+			fmt.Printf("Warning: failed to convert document to PDF: %v\n", err)
+			return
+		}
+		result.PreviewFullPath = previewFull
+		result.PreviewRelativePath = previewRel
+		pdfPath = previewFull
+	}
+
+	if meta, err := ExtractDocumentMetadata(pdfPath); err == nil {
+		result.Document = meta
+	} else {
+		fmt.Printf("Warning: failed to extract document metadata: %v\n", err)
+		result.Document = &DocumentMetadata{}
+	}
+
+	tempDir, err := os.MkdirTemp("", "page-")
+	if err != nil {
+		fmt.Printf("Warning: failed to create temp directory: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	pagePath := filepath.Join(tempDir, "page.jpg")
+	if err := RenderDocumentPage(pdfPath, pagePath); err != nil {
+		fmt.Printf("Warning: failed to render document page: %v\n", err)
+		return
+	}
+
+	if f, err := os.Open(pagePath); err == nil {
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			result.Document.Width, result.Document.Height = cfg.Width, cfg.Height
+		}
+		f.Close()
+	}
+
+	if thumbFull, thumbRel, err := GenerateImageThumbnail(pagePath, relativePath); err == nil {
+		result.ThumbnailFullPath = thumbFull
+		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
+	} else {
+		fmt.Printf("Warning: failed to generate thumbnail for document: %v\n", err)
+	}
+}
+
+func populateItemDocument(item *models.MediaItem, meta *DocumentMetadata) {
+	item.PageCount = meta.PageCount
+	item.Width = meta.Width
+	item.Height = meta.Height
+}
+
+// storeDocumentText saves the text extracted from a new document for search.
+// Failures are logged; the document just won't be found by its contents.
+func storeDocumentText(ctx context.Context, svc *service.MediaService, id uuid.UUID, meta *DocumentMetadata) {
+	if meta == nil || meta.Text == "" {
+		return
+	}
+	if err := svc.SetDocumentText(ctx, id, meta.Text); err != nil {
+		log.Printf("Document: failed to store text for %s: %v", id, err)
+	}
+}
+
+// resolveDocumentPath returns the PDF to view a document as: the original
+// PDF, or the PDF preview of an office file. Falls back to the original.
+func resolveDocumentPath(item *models.MediaItem) (fullPath, contentType string) {
+	basePath := getMediaBasePath()
+	if item.PreviewPath != "" {
+		previewPath := filepath.Join(basePath, item.PreviewPath)
+		if fileExists(previewPath) {
+			return previewPath, "application/pdf"
+		}
+	}
+	return filepath.Join(basePath, item.Path), item.MimeType
+}
+
+// setDocumentInline marks a document response for display in the browser's
+// viewer rather than download, under its original name
+func setDocumentInline(w http.ResponseWriter, item *models.MediaItem, fullPath string) {
+	name := item.OriginalFilename
+	if name == "" {
+		name = filepath.Base(item.Path)
+	}
+	if filepath.Ext(fullPath) == ".pdf" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ".pdf"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// Layout of household export archives
+const (
+	exportManifestName = "manifest.json"
+	exportOriginalsDir = "originals"
+)
+
+// ImportResult summarizes a household import
+type ImportResult struct {
+	UsersCreated  int      `json:"usersCreated"`
+	UsersSkipped  int      `json:"usersSkipped"`
+	ItemsImported int      `json:"itemsImported"`
+	ItemsSkipped  int      `json:"itemsSkipped"` // Already present (same SHA256)
+	Errors        []string `json:"errors,omitempty"`
+}
+
+type AdminHandler struct {
+	householdSvc *service.HouseholdService
+	userSvc      *service.UserService
+	mediaSvc     *service.MediaService
+}
+
+func NewAdminHandler(householdSvc *service.HouseholdService, userSvc *service.UserService, mediaSvc *service.MediaService) *AdminHandler {
+	return &AdminHandler{householdSvc: householdSvc, userSvc: userSvc, mediaSvc: mediaSvc}
+}
+
+// Export handles GET /admin/export
+// Streams a portable archive of the admin's household: originals plus a JSON
+// manifest of the household, its users and every media item.
+func (h *AdminHandler) Export(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser := lookupCurrentUser(r, h.userSvc)
+	if !currentUser.IsAdmin() || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "only household admins can export",
+		})
+		return
+	}
+
+	export, err := BuildHouseholdExport(r.Context(), h.householdSvc, h.userSvc, h.mediaSvc, householdID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to build export: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"household-export-%s.zip\"",
+		export.ExportedAt.Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	if err := WriteHouseholdExport(r.Context(), w, export); err != nil {
+		log.Printf("Household export failed: %v", err)
+	}
+}
+
+// BuildHouseholdExport loads every record of a household for export
+func BuildHouseholdExport(ctx context.Context, householdSvc *service.HouseholdService, userSvc *service.UserService, mediaSvc *service.MediaService, householdID uuid.UUID) (*models.HouseholdExport, error) {
+	household, err := householdSvc.GetByID(ctx, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household: %w", err)
+	}
+
+	users, err := userSvc.ListByHousehold(ctx, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	items, err := mediaSvc.ListAllByHousehold(ctx, householdID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+	if items == nil {
+		items = []models.MediaItem{}
+	}
+
+	return &models.HouseholdExport{
+		Version:    models.HouseholdExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Household:  *household,
+		Users:      users,
+		Items:      items,
+	}, nil
+}
+
+// WriteHouseholdExport streams the export archive to w. Originals missing on
+// disk are logged and left out; their records stay in the manifest.
+func WriteHouseholdExport(ctx context.Context, w io.Writer, export *models.HouseholdExport) error {
+	zw := zip.NewWriter(w)
+
+	manifest, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	mw, err := zw.Create(exportManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(manifest); err != nil {
+		return err
+	}
+
+	basePath := getMediaBasePath()
+	for i := range export.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		item := &export.Items[i]
+		if err := addFileToZip(zw, filepath.Join(basePath, item.Path), exportEntryName(item), item.CreatedAt); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				log.Printf("Export: original missing for %s (%s)", item.ID, item.Path)
+				continue
+			}
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// exportEntryName is the archive path of an item's original
+func exportEntryName(item *models.MediaItem) string {
+	return path.Join(exportOriginalsDir, filepath.ToSlash(item.Path))
+}
+
+// addFileToZip stores a file uncompressed (media is already compressed)
+func addFileToZip(zw *zip.Writer, srcPath, name string, modified time.Time) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, src); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ImportHouseholdArchive restores an export archive into the database and
+// media root. The household and users are created if missing; items whose
+// SHA256 already exists in the household are skipped. Derivatives (previews,
+// thumbnails, WebP) are regenerated from the restored originals.
+func ImportHouseholdArchive(ctx context.Context, archivePath string, householdSvc *service.HouseholdService, userSvc *service.UserService, mediaSvc *service.MediaService) (*ImportResult, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer zr.Close()
+
+	export, err := readExportManifest(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+
+	if _, err := householdSvc.GetByID(ctx, export.Household.ID); errors.Is(err, models.ErrNotFound) {
+		if err := householdSvc.Create(ctx, &export.Household); err != nil {
+			return nil, fmt.Errorf("failed to create household: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get household: %w", err)
+	}
+
+	// Users that could not be restored are dropped as uploaders
+	knownUsers := make(map[uuid.UUID]bool)
+	for i := range export.Users {
+		u := &export.Users[i]
+		if _, err := userSvc.GetByID(ctx, u.ID); err == nil {
+			knownUsers[u.ID] = true
+			result.UsersSkipped++
+			continue
+		}
+		if err := userSvc.Create(ctx, u); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("user %s: %v", u.ID, err))
+			continue
+		}
+		knownUsers[u.ID] = true
+		result.UsersCreated++
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	for i := range export.Items {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		item := &export.Items[i]
+		if item.HouseholdID != export.Household.ID {
+			result.Errors = append(result.Errors, fmt.Sprintf("item %s: belongs to another household", item.ID))
+			continue
+		}
+		if item.UploaderID != nil && !knownUsers[*item.UploaderID] {
+			item.UploaderID = nil
+		}
+
+		imported, err := importItem(ctx, mediaSvc, files[exportEntryName(item)], item)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("item %s (%s): %v", item.ID, item.Path, err))
+			continue
+		}
+		if imported {
+			result.ItemsImported++
+		} else {
+			result.ItemsSkipped++
+		}
+	}
+
+	return result, nil
+}
+
+// readExportManifest reads and checks the manifest of an export archive
+func readExportManifest(zr *zip.Reader) (*models.HouseholdExport, error) {
+	f, err := zr.Open(exportManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("archive has no %s: %w", exportManifestName, err)
+	}
+	defer f.Close()
+
+	var export models.HouseholdExport
+	if err := json.NewDecoder(f).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if export.Version != models.HouseholdExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+	return &export, nil
+}
+
+// importItem restores one item. Returns false without error if an item with
+// the same SHA256 already exists.
+func importItem(ctx context.Context, mediaSvc *service.MediaService, f *zip.File, item *models.MediaItem) (bool, error) {
+	if item.SHA256 != "" {
+		if _, err := mediaSvc.GetBySHA256(ctx, item.HouseholdID, item.SHA256); err == nil {
+			return false, nil
+		} else if !errors.Is(err, models.ErrNotFound) {
+			return false, err
+		}
+	}
+
+	if f == nil {
+		return false, errors.New("original missing from archive")
+	}
+	if !filepath.IsLocal(item.Path) {
+		return false, errors.New("unsafe path")
+	}
+
+	fullPath := filepath.Join(getMediaBasePath(), item.Path)
+	if fileExists(fullPath) {
+		return false, errors.New("a different file already exists at this path")
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return false, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := extractZipFile(f, fullPath, item.SHA256); err != nil {
+		return false, err
+	}
+
+	// Regenerate derivatives; metadata from the manifest is kept as-is
+	saved := &SavedFile{RelativePath: item.Path, FullPath: fullPath}
+	processMediaFile(saved, fullPath, item.Path, item.MimeType)
+	if item.PosterTimeSec != nil {
+		applyPosterFrame(saved, fullPath, item.Path, *item.PosterTimeSec)
+	}
+	item.PreviewPath = saved.PreviewRelativePath
+	item.ThumbnailPath = saved.ThumbnailRelPath
+	item.WebPath = saved.WebRelPath
+	item.AvifPath = saved.AvifRelPath
+	item.BlurHash = saved.BlurHash
+	item.DominantColor = saved.DominantColor
+	item.AnimatedThumbnailPath = saved.AnimThumbRelPath
+
+	// Video renditions aren't regenerated here but queued for the server's
+	// transcoders, as on upload
+	item.WebVideoStatus = ""
+	queueWebVideo(item)
+	item.HLSStatus = ""
+	item.HLSProgress = 0
+	item.HLSPath = ""
+
+	if err := mediaSvc.Create(ctx, item); err != nil {
+		CleanupFiles(fullPath, saved.PreviewFullPath, saved.ThumbnailFullPath, saved.WebFullPath, saved.AvifFullPath, saved.AnimThumbFullPath)
+		return false, fmt.Errorf("failed to save to database: %w", err)
+	}
+	// Document text isn't in the manifest; it was re-extracted above
+	storeDocumentText(ctx, mediaSvc, item.ID, saved.Document)
+	return true, nil
+}
+
+// extractZipFile writes an archive entry to destPath and verifies its SHA256
+func extractZipFile(f *zip.File, destPath, wantSHA256 string) error {
+	src, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read archive entry: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer dst.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(dst, io.TeeReader(src, hasher)); err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("failed to extract file: %w", err)
+	}
+
+	if wantSHA256 != "" && hex.EncodeToString(hasher.Sum(nil)) != wantSHA256 {
+		os.Remove(destPath)
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+)
+
+// ratingRequest is the request body for PUT /media/{id}/rating
+type ratingRequest struct {
+	Rating int `json:"rating"`
+}
+
+// getViewableItem resolves the current user and the {id} media item, and checks
+// that the user can see it. Writes an error response and returns nils otherwise.
+func (h *MediaHandler) getViewableItem(w http.ResponseWriter, r *http.Request) (*models.User, *models.MediaItem) {
+	return resolveViewableItem(w, r, h.svc, h.userSvc)
+}
+
+// resolveViewableItem is the shared implementation of getViewableItem for
+// handlers of media sub-resources.
+func resolveViewableItem(w http.ResponseWriter, r *http.Request, svc *service.MediaService, userSvc *service.UserService) (*models.User, *models.MediaItem) {
+	id, ok := parseMediaID(w, r)
+	if !ok {
+		return nil, nil
+	}
+
+	currentUser := lookupCurrentUser(r, userSvc)
+	if currentUser == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return nil, nil
+	}
+
+	item := fetchMediaItem(w, r, svc, id)
+	if item == nil {
+		return nil, nil
+	}
+
+	if !canView(currentUser, item) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "media item not found",
+		})
+		return nil, nil
+	}
+
+	return currentUser, item
+}
+
+// Favorite handles PUT /media/{id}/favorite
+func (h *MediaHandler) Favorite(w http.ResponseWriter, r *http.Request) {
+	h.setFavorite(w, r, true)
+}
+
+// Unfavorite handles DELETE /media/{id}/favorite
+func (h *MediaHandler) Unfavorite(w http.ResponseWriter, r *http.Request) {
+	h.setFavorite(w, r, false)
+}
+
+func (h *MediaHandler) setFavorite(w http.ResponseWriter, r *http.Request, favorite bool) {
+	currentUser, item := h.getViewableItem(w, r)
+	if item == nil {
+		return
+	}
+
+	if err := h.svc.SetFavorite(r.Context(), item.ID, currentUser.ID, favorite); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to update favorite: %v", err),
+		})
+		return
+	}
+
+	h.writeUserState(w, r, item, currentUser)
+}
+
+// Rate handles PUT /media/{id}/rating
+// Body: {"rating": 0-5}; 0 clears the rating.
+func (h *MediaHandler) Rate(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := h.getViewableItem(w, r)
+	if item == nil {
+		return
+	}
+
+	var req ratingRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.svc.SetRating(r.Context(), item.ID, currentUser.ID, req.Rating); err != nil {
+		if errors.Is(err, models.ErrInvalidInput) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to update rating: %v", err),
+		})
+		return
+	}
+
+	h.writeUserState(w, r, item, currentUser)
+}
+
+// writeUserState responds with the user's current state for the item
+func (h *MediaHandler) writeUserState(w http.ResponseWriter, r *http.Request, item *models.MediaItem, u *models.User) {
+	state, err := h.svc.GetUserState(r.Context(), item.ID, u.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to load favorite state: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"state": state})
+}
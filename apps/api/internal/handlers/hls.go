@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HLSSegmentSeconds is the target length of each HLS segment
+const HLSSegmentSeconds = 6
+
+// hlsRendition is one step of the HLS bitrate ladder
+type hlsRendition struct {
+	Height    int // Short side, so portrait videos get the same quality
+	VideoKbps int
+	AudioKbps int
+}
+
+// HLSRenditions is the bitrate ladder. Renditions taller than the source are
+// skipped; the smallest is always generated.
+var HLSRenditions = []hlsRendition{
+	{Height: 360, VideoKbps: 800, AudioKbps: 96},
+	{Height: 720, VideoKbps: 2800, AudioKbps: 128},
+	{Height: 1080, VideoKbps: 5000, AudioKbps: 128},
+}
+
+// hlsContentTypes are the files an HLS directory may serve
+var hlsContentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+}
+
+// getHLSDir returns the directory holding a video's playlists and segments
+func getHLSDir(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".hls", relativePath, "")
+}
+
+// hlsLadder returns the renditions to generate for a video
+func hlsLadder(meta *VideoMetadata) []hlsRendition {
+	shortSide := min(meta.Width, meta.Height)
+	ladder := HLSRenditions[:1]
+	for i, r := range HLSRenditions {
+		if r.Height <= shortSide {
+			ladder = HLSRenditions[:i+1]
+		}
+	}
+	return ladder
+}
+
+// GenerateHLS transcodes a video into an HLS bitrate ladder and returns the
+// master playlist. onProgress is called with the percentage done as ffmpeg
+// advances. Output is written to a temporary directory and moved into place
+// when complete.
+func GenerateHLS(ctx context.Context, srcPath, originalRelPath string, meta *VideoMetadata, onProgress func(percent int)) (fullPath, relPath string, err error) {
+	dir, relDir := getHLSDir(originalRelPath)
+	tempDir := dir + ".temp"
+	os.RemoveAll(tempDir)
+	defer os.RemoveAll(tempDir)
+
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create hls directory: %w", err)
+	}
+
+	ladder := hlsLadder(meta)
+
+	// Split the decoded video once per rendition and scale each (never up)
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "[0:v:0]split=%d", len(ladder))
+	for i := range ladder {
+		fmt.Fprintf(&filter, "[s%d]", i)
+	}
+	for i, r := range ladder {
+		fmt.Fprintf(&filter,
+			";[s%[1]d]scale='if(gte(iw,ih),-2,min(%[2]d,iw))':'if(gte(iw,ih),min(%[2]d,ih),-2)'[v%[1]d]",
+			i, r.Height)
+	}
+
+	args := []string{"-y", "-i", srcPath, "-filter_complex", filter.String()}
+	var streamMap []string
+	for i, r := range ladder {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%d]", i),
+			fmt.Sprintf("-b:v:%d", i), fmt.Sprintf("%dk", r.VideoKbps),
+			fmt.Sprintf("-maxrate:v:%d", i), fmt.Sprintf("%dk", r.VideoKbps*107/100),
+			fmt.Sprintf("-bufsize:v:%d", i), fmt.Sprintf("%dk", r.VideoKbps*3/2),
+		)
+		if meta.HasAudio {
+			args = append(args,
+				"-map", "0:a:0",
+				fmt.Sprintf("-b:a:%d", i), fmt.Sprintf("%dk", r.AudioKbps),
+			)
+			streamMap = append(streamMap, fmt.Sprintf("v:%d,a:%d", i, i))
+		} else {
+			streamMap = append(streamMap, fmt.Sprintf("v:%d", i))
+		}
+	}
+
+	args = append(args,
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "main",
+		"-pix_fmt", "yuv420p",
+		// Keyframes on segment boundaries so renditions can be switched
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", HLSSegmentSeconds),
+		"-c:a", "aac",
+		"-ac", "2",
+		"-map_metadata", "-1",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(HLSSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(tempDir, "v%v_%04d.ts"),
+		"-master_pl_name", "master.m3u8",
+		"-progress", "pipe:1",
+		"-nostats",
+		filepath.Join(tempDir, "v%v.m3u8"),
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", "", fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	readFFmpegProgress(stdout, meta.DurationSec, onProgress)
+	if err := cmd.Wait(); err != nil {
+		return "", "", fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(stderr.String(), 5))
+	}
+
+	os.RemoveAll(dir)
+	if err := os.Rename(tempDir, dir); err != nil {
+		return "", "", fmt.Errorf("failed to save hls stream: %w", err)
+	}
+
+	return filepath.Join(dir, "master.m3u8"), filepath.Join(relDir, "master.m3u8"), nil
+}
+
+// readFFmpegProgress parses `ffmpeg -progress` output and reports whole
+// percentages (capped at 99 until ffmpeg exits) as they change.
+func readFFmpegProgress(r io.Reader, durationSec float64, onProgress func(percent int)) {
+	last := -1
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		// Despite the name, out_time_ms is in microseconds
+		if key != "out_time_us" && key != "out_time_ms" {
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || durationSec <= 0 {
+			continue
+		}
+		percent := min(99, int(float64(us)/1e4/durationSec))
+		if percent > last {
+			last = percent
+			onProgress(percent)
+		}
+	}
+}
+
+// EnableHLS turns on HLS generation for videos at least minDuration long.
+// RunHLSTranscoder must be running to process the queue.
+func (h *MediaHandler) EnableHLS(minDuration time.Duration) {
+	h.hlsMinDuration = minDuration
+	h.hlsWake = make(chan struct{}, 1)
+}
+
+// queueHLS marks a new video for HLS generation if it is long enough. Call
+// before the item is created; wakeHLS starts the work after.
+func (h *MediaHandler) queueHLS(item *models.MediaItem) {
+	if h.hlsMinDuration <= 0 || item.Type != "video" {
+		return
+	}
+	if time.Duration(item.DurationSec)*time.Second >= h.hlsMinDuration {
+		item.HLSStatus = models.HLSStatusPending
+	}
+}
+
+// wakeHLS nudges the transcoder to check the queue without waiting for its
+// next poll
+func (h *MediaHandler) wakeHLS() {
+	select {
+	case h.hlsWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunHLSTranscoder generates HLS streams for queued videos, one at a time,
+// until ctx is cancelled. The queue lives in the database, so videos uploaded
+// before HLS was enabled and work interrupted by a restart are picked up too.
+func (h *MediaHandler) RunHLSTranscoder(ctx context.Context, interval time.Duration) {
+	if n, err := h.svc.ResetHLSProcessing(ctx); err != nil {
+		log.Printf("HLS: failed to requeue interrupted streams: %v", err)
+	} else if n > 0 {
+		log.Printf("HLS: requeued %d interrupted streams", n)
+	}
+
+	runTranscodeQueue(ctx, interval, h.hlsWake, "HLS", h.transcodeNextHLS)
+}
+
+// runTranscodeQueue calls next until the queue is empty, then waits for the
+// poll interval or a wake-up and starts over, until ctx is cancelled. next
+// returns false when there was nothing to do; errors are logged as prefix.
+func runTranscodeQueue(ctx context.Context, interval time.Duration, wake <-chan struct{}, prefix string, next func(ctx context.Context) (bool, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			ok, err := next(ctx)
+			if err != nil {
+				log.Printf("%s: %v", prefix, err)
+				break
+			}
+			if !ok {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// transcodeNextHLS generates the stream for the next queued video. Returns
+// false if the queue is empty.
+func (h *MediaHandler) transcodeNextHLS(ctx context.Context) (bool, error) {
+	item, err := h.svc.NextHLSCandidate(ctx, h.hlsMinDuration)
+	if errors.Is(err, models.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to find queued videos: %w", err)
+	}
+
+	if err := h.svc.SetHLSStatus(ctx, item.ID, models.HLSStatusProcessing, 0); err != nil {
+		return false, fmt.Errorf("failed to claim %s: %w", item.ID, err)
+	}
+	log.Printf("HLS: generating stream for %s", item.Path)
+
+	fullPath, relPath, err := h.generateItemHLS(ctx, item)
+	if ctx.Err() != nil {
+		// Shutting down; the item is requeued on the next start
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("HLS: failed for %s: %v", item.Path, err)
+		if err := h.svc.SetHLSStatus(ctx, item.ID, models.HLSStatusFailed, 0); err != nil {
+			return false, fmt.Errorf("failed to update %s: %w", item.ID, err)
+		}
+		return true, nil
+	}
+
+	if err := h.svc.SetHLSReady(ctx, item.ID, relPath); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			// Deleted while transcoding
+			os.RemoveAll(filepath.Dir(fullPath))
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to update %s: %w", item.ID, err)
+	}
+	log.Printf("HLS: stream ready for %s", item.Path)
+	return true, nil
+}
+
+func (h *MediaHandler) generateItemHLS(ctx context.Context, item *models.MediaItem) (fullPath, relPath string, err error) {
+	srcPath := filepath.Join(getMediaBasePath(), item.Path)
+	meta, err := ExtractVideoMetadata(srcPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return GenerateHLS(ctx, srcPath, item.Path, meta, func(percent int) {
+		if err := h.svc.SetHLSStatus(ctx, item.ID, models.HLSStatusProcessing, percent); err != nil {
+			log.Printf("HLS: failed to record progress for %s: %v", item.ID, err)
+		}
+	})
+}
+
+// HLS handles GET /media/{id}/hls/*
+// Serves the master playlist, variant playlists and segments of a video's
+// HLS stream. Playlists use relative URIs, so players request everything
+// through this route with the same authorization.
+func (h *MediaHandler) HLS(w http.ResponseWriter, r *http.Request) {
+	_, item := h.getViewableItem(w, r)
+	if item == nil {
+		return
+	}
+
+	if item.HLSStatus != models.HLSStatusReady || item.HLSPath == "" {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error":     "stream not available",
+			"hlsStatus": item.HLSStatus,
+		})
+		return
+	}
+
+	name := chi.URLParam(r, "*")
+	contentType, ok := hlsContentTypes[strings.ToLower(filepath.Ext(name))]
+	if !ok || !filepath.IsLocal(name) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "file not found",
+		})
+		return
+	}
+
+	fullPath := filepath.Join(getMediaBasePath(), filepath.Dir(item.HLSPath), name)
+	if !fileExists(fullPath) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "file not found",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, fullPath)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Live Photo videos are about three seconds long. Pairs matched by file name
+// (without a content identifier) must also have been taken this close together.
+const (
+	livePhotoMaxDurationSec = 5
+	livePhotoMaxTimeGap     = 3 * time.Second
+)
+
+// appleMakerNoteHeader starts the MakerNote of photos taken on iOS devices
+var appleMakerNoteHeader = []byte("Apple iOS\x00")
+
+// appleContentIdentifierTag is the MakerNote tag holding the content identifier
+const appleContentIdentifierTag = 0x0011
+
+// appleContentIdentifier reads the content identifier from an Apple MakerNote.
+// The MakerNote is a header, a version and a byte order mark followed by a
+// TIFF-style IFD whose offsets are relative to the start of the MakerNote.
+func appleContentIdentifier(makerNote []byte) string {
+	if !bytes.HasPrefix(makerNote, appleMakerNoteHeader) || len(makerNote) < 16 {
+		return ""
+	}
+
+	var order binary.ByteOrder
+	switch string(makerNote[12:14]) {
+	case "MM":
+		order = binary.BigEndian
+	case "II":
+		order = binary.LittleEndian
+	default:
+		return ""
+	}
+
+	count := int(order.Uint16(makerNote[14:16]))
+	for i := range count {
+		entry := 16 + i*12
+		if entry+12 > len(makerNote) {
+			return ""
+		}
+		tag := order.Uint16(makerNote[entry:])
+		typ := order.Uint16(makerNote[entry+2:])
+		n := int(order.Uint32(makerNote[entry+4:]))
+		if tag != appleContentIdentifierTag || typ != 2 { // 2 = ASCII
+			continue
+		}
+
+		value := makerNote[entry+8 : entry+12]
+		if n > 4 {
+			offset := int(order.Uint32(makerNote[entry+8:]))
+			if offset < 0 || n > len(makerNote) || offset > len(makerNote)-n {
+				return ""
+			}
+			value = makerNote[offset : offset+n]
+		} else {
+			value = value[:n]
+		}
+		return strings.TrimRight(string(value), "\x00 ")
+	}
+	return ""
+}
+
+// pairLivePhoto links a newly created item to the other half of its Live
+// Photo if that has already been uploaded. Either half may arrive first.
+// Failures are logged; the item is simply left unpaired.
+func (h *MediaHandler) pairLivePhoto(ctx context.Context, item *models.MediaItem) {
+	wantType := "video"
+	switch {
+	case item.Type == "photo":
+	case item.Type == "video" && item.DurationSec <= livePhotoMaxDurationSec:
+		wantType = "photo"
+	default:
+		return
+	}
+
+	candidates, err := h.svc.FindLivePhotoCandidates(ctx, item.HouseholdID, wantType, item.ContentIdentifier, derivedBaseName(item))
+	if err != nil {
+		log.Printf("Live Photo: failed to find pair for %s: %v", item.ID, err)
+		return
+	}
+
+	match := matchLivePhoto(item, candidates)
+	if match == nil {
+		return
+	}
+
+	photo, video := item, match
+	if item.Type == "video" {
+		photo, video = match, item
+	}
+	if err := h.svc.LinkLivePhoto(ctx, photo.ID, video.ID); err != nil {
+		log.Printf("Live Photo: failed to link %s and %s: %v", photo.ID, video.ID, err)
+		return
+	}
+	photo.LivePhotoVideoID = &video.ID
+	video.LivePhotoID = &photo.ID
+}
+
+// matchLivePhoto picks the other half of item's Live Photo from candidates.
+// A shared content identifier is conclusive. Otherwise the file names must
+// match and the capture times be within livePhotoMaxTimeGap.
+func matchLivePhoto(item *models.MediaItem, candidates []models.MediaItem) *models.MediaItem {
+	var byName *models.MediaItem
+	for i := range candidates {
+		c := &candidates[i]
+		if c.ID == item.ID || !sameUploader(c.UploaderID, item.UploaderID) {
+			continue
+		}
+
+		if item.ContentIdentifier != "" && c.ContentIdentifier == item.ContentIdentifier {
+			return c
+		}
+		if item.ContentIdentifier != "" && c.ContentIdentifier != "" {
+			continue // Both known and different
+		}
+
+		video := c
+		if item.Type == "video" {
+			video = item
+		}
+		if byName == nil && video.DurationSec <= livePhotoMaxDurationSec &&
+			strings.EqualFold(derivedBaseName(c), derivedBaseName(item)) &&
+			takenWithin(c.TakenAt, item.TakenAt, livePhotoMaxTimeGap) {
+			byName = c
+		}
+	}
+	return byName
+}
+
+// sameUploader reports whether two items were uploaded by the same user
+func sameUploader(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// takenWithin reports whether both capture times are known and at most gap apart
+func takenWithin(a, b *time.Time, gap time.Duration) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	d := a.Sub(*b)
+	return d <= gap && d >= -gap
+}
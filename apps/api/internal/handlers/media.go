@@ -3,18 +3,19 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"storage-api/internal/models"
 	"storage-api/internal/repository"
 	"storage-api/internal/service"
 
-	"github.com/clerk/clerk-sdk-go/v2"
-	"github.com/clerk/clerk-sdk-go/v2/user"
-	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
@@ -26,38 +27,51 @@ const (
 type MediaHandler struct {
 	svc     *service.MediaService
 	userSvc *service.UserService
+	signer  *service.MediaURLSigner
+
+	// MIME types accepted for upload; see SetAllowedUploadTypes
+	allowedTypes []string
+
+	// HLS generation, configured by EnableHLS
+	hlsMinDuration time.Duration
+	hlsWake        chan struct{}
+
+	// Wakes RunWebVideoTranscoder when a video is queued
+	webVideoWake chan struct{}
+}
+
+func NewMediaHandler(svc *service.MediaService, userSvc *service.UserService, signer *service.MediaURLSigner) *MediaHandler {
+	return &MediaHandler{
+		svc:          svc,
+		userSvc:      userSvc,
+		signer:       signer,
+		allowedTypes: DefaultUploadTypes,
+		webVideoWake: make(chan struct{}, 1),
+	}
 }
 
-func NewMediaHandler(svc *service.MediaService, userSvc *service.UserService) *MediaHandler {
-	return &MediaHandler{svc: svc, userSvc: userSvc}
+// SetAllowedUploadTypes replaces DefaultUploadTypes as the MIME types accepted
+// for upload. Entries may be a whole category, as in "image/*".
+func (h *MediaHandler) SetAllowedUploadTypes(types []string) {
+	h.allowedTypes = types
 }
 
 // parseMediaID extracts and validates a UUID from the URL path parameter.
 // Returns the parsed UUID, or writes an error response and returns false.
 func parseMediaID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
-	idStr := chi.URLParam(r, "id")
-	if idStr == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]any{
-			"error": "missing id parameter",
-		})
-		return uuid.UUID{}, false
-	}
-
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{
-			"error": "invalid id parameter",
-		})
-		return uuid.UUID{}, false
-	}
-
-	return id, true
+	return parseUUIDParam(w, r, "id")
 }
 
 // getMediaItem fetches a media item by ID, handling not-found and error cases.
 // Returns the item, or writes an error response and returns nil.
 func (h *MediaHandler) getMediaItem(w http.ResponseWriter, r *http.Request, id uuid.UUID) *models.MediaItem {
-	item, err := h.svc.GetByID(r.Context(), id)
+	return fetchMediaItem(w, r, h.svc, id)
+}
+
+// fetchMediaItem fetches a media item by ID, handling not-found and error cases.
+// Returns the item, or writes an error response and returns nil.
+func fetchMediaItem(w http.ResponseWriter, r *http.Request, svc *service.MediaService, id uuid.UUID) *models.MediaItem {
+	item, err := svc.GetByID(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNotFound) {
 			writeJSON(w, http.StatusNotFound, map[string]any{
@@ -125,19 +139,56 @@ func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Determine media type
+	// Determine the media type from the content; the client's Content-Type
+	// and file name can't be trusted
+	head, err := readSniffHead(file)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to read upload: %v", err),
+		})
+		return
+	}
+	mimeType, err := detectUploadType(head, header.Filename, header.Header.Get("Content-Type"))
+	if errors.Is(err, errUnrecognizedType) {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	detectedType := detectMediaType(mimeType)
+	if detectedType == "" || !uploadTypeAllowed(mimeType, h.allowedTypes) {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]any{
+			"error": fmt.Sprintf("file type %s is not allowed", mimeType),
+		})
+		return
+	}
 	mediaType := r.FormValue("type")
-	mimeType := header.Header.Get("Content-Type")
 	if mediaType == "" {
-		mediaType = detectMediaType(mimeType)
+		mediaType = detectedType
 	}
-	if mediaType != "photo" && mediaType != "video" {
+	if mediaType != detectedType {
 		writeJSON(w, http.StatusBadRequest, map[string]any{
-			"error": "type must be 'photo' or 'video'",
+			"error": fmt.Sprintf("type %q does not match the file's content (%s)", mediaType, mimeType),
 		})
 		return
 	}
 
+	// Refuse decompression bombs before anything decodes them
+	if mediaType == "photo" {
+		if err := checkImagePixels(io.NewSectionReader(file, 0, header.Size)); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
 	// Parse is_private flag (defaults to false/public)
 	isPrivate := r.FormValue("is_private") == "true"
 
@@ -153,7 +204,7 @@ func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	// Check if file already exists (by path)
 	existing, err := h.svc.GetByPath(r.Context(), householdID, saved.RelativePath)
 	if err == nil {
-		CleanupFiles(saved.FullPath, saved.PreviewFullPath, saved.WebFullPath)
+		CleanupFiles(saved.FullPath, saved.PreviewFullPath, saved.WebFullPath, saved.AvifFullPath, saved.AnimThumbFullPath)
 		writeJSON(w, http.StatusConflict, map[string]any{
 			"error":    "file already exists",
 			"existing": existing,
@@ -173,6 +224,9 @@ func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		PreviewPath:      saved.PreviewRelativePath,
 		ThumbnailPath:    saved.ThumbnailRelPath,
 		WebPath:          saved.WebRelPath,
+		AvifPath:         saved.AvifRelPath,
+		BlurHash:         saved.BlurHash,
+		DominantColor:    saved.DominantColor,
 		OriginalFilename: header.Filename,
 	}
 
@@ -185,15 +239,41 @@ func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	if saved.Metadata != nil {
 		populateItemMetadata(item, saved.Metadata)
 	}
+	if saved.Video != nil {
+		populateItemVideo(item, saved.Video)
+	}
+	if saved.Animation != nil {
+		populateItemAnimation(item, saved.Animation)
+	}
+	if saved.Document != nil {
+		populateItemDocument(item, saved.Document)
+	}
+	if saved.Audio != nil {
+		populateItemAudio(item, saved.Audio)
+	}
+	item.AnimatedThumbnailPath = saved.AnimThumbRelPath
+	queueWebVideo(item)
+	h.queueHLS(item)
 
 	if err := h.svc.Create(r.Context(), item); err != nil {
-		CleanupFiles(saved.FullPath, saved.PreviewFullPath, saved.WebFullPath)
+		CleanupFiles(saved.FullPath, saved.PreviewFullPath, saved.WebFullPath, saved.AvifFullPath, saved.AnimThumbFullPath)
 		writeJSON(w, http.StatusInternalServerError, map[string]any{
 			"error": fmt.Sprintf("failed to save to database: %v", err),
 		})
 		return
 	}
 
+	if item.WebVideoStatus == models.WebVideoStatusPending {
+		h.wakeWebVideo()
+	}
+	if item.HLSStatus == models.HLSStatusPending {
+		h.wakeHLS()
+	}
+	h.pairLivePhoto(r.Context(), item)
+	h.stackRAWPair(r.Context(), item)
+	storeDocumentText(r.Context(), h.svc, item.ID, saved.Document)
+
+	item.URLs = h.signer.URLs(item)
 	writeJSON(w, http.StatusCreated, map[string]any{
 		"message": "upload successful",
 		"item":    item,
@@ -213,6 +293,29 @@ func populateItemMetadata(item *models.MediaItem, meta *ImageMetadata) {
 	item.FNumber = meta.FNumber
 	item.ExposureTime = meta.ExposureTime
 	item.FocalLength = meta.FocalLength
+	item.ContentIdentifier = meta.ContentIdentifier
+}
+
+func populateItemVideo(item *models.MediaItem, meta *VideoMetadata) {
+	item.DurationSec = int(math.Round(meta.DurationSec))
+	item.Width = meta.Width
+	item.Height = meta.Height
+	item.Rotation = meta.Rotation
+	item.VideoCodec = meta.VideoCodec
+	item.Bitrate = meta.Bitrate
+	item.FrameRate = meta.FrameRate
+	item.TakenAt = meta.TakenAt
+	item.Latitude = meta.Latitude
+	item.Longitude = meta.Longitude
+	item.CameraMake = meta.CameraMake
+	item.CameraModel = meta.CameraModel
+	item.ContentIdentifier = meta.ContentIdentifier
+}
+
+func populateItemAnimation(item *models.MediaItem, anim *AnimationInfo) {
+	item.IsAnimated = true
+	item.FrameCount = anim.FrameCount
+	item.AnimationDurationMs = anim.DurationMs
 }
 
 // List handles GET /media
@@ -223,14 +326,7 @@ func (h *MediaHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse pagination params
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
+	page, pageSize := parsePagination(r)
 
 	// Parse visibility and type filters
 	visibility := r.URL.Query().Get("visibility")
@@ -239,6 +335,18 @@ func (h *MediaHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 	mediaType := r.URL.Query().Get("type")
 
+	// Parse per-user favorite/rating filters and sort order
+	favoritesOnly := r.URL.Query().Get("favorite") == "true"
+	minRating, _ := strconv.Atoi(r.URL.Query().Get("minRating"))
+	sort := r.URL.Query().Get("sort")
+
+	// Archived items are hidden unless requested, but included in searches
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	archived := r.URL.Query().Get("archived")
+	if archived == "" && search != "" {
+		archived = "all"
+	}
+
 	// Get current user ID from database (for visibility filtering)
 	var userID *uuid.UUID
 	if u := h.getCurrentUser(r); u != nil {
@@ -250,8 +358,14 @@ func (h *MediaHandler) List(w http.ResponseWriter, r *http.Request) {
 		UserID:      userID,
 		Visibility:  visibility,
 		MediaType:   mediaType,
+		Sort:        sort,
+		Archived:    archived,
+		Search:      search,
 		Page:        page,
 		PageSize:    pageSize,
+
+		FavoritesOnly: favoritesOnly,
+		MinRating:     minRating,
 	}
 
 	items, totalCount, err := h.svc.List(r.Context(), filter)
@@ -265,6 +379,7 @@ func (h *MediaHandler) List(w http.ResponseWriter, r *http.Request) {
 	if items == nil {
 		items = []models.MediaItem{}
 	}
+	signItems(h.signer, items)
 
 	writeJSON(w, http.StatusOK, models.MediaListResponse{
 		Items:      items,
@@ -276,37 +391,7 @@ func (h *MediaHandler) List(w http.ResponseWriter, r *http.Request) {
 
 // getCurrentUser looks up the current user from Clerk claims
 func (h *MediaHandler) getCurrentUser(r *http.Request) *models.User {
-	claims, ok := clerk.SessionClaimsFromContext(r.Context())
-	if !ok {
-		return nil
-	}
-
-	// Fetch user from Clerk to get email
-	clerkUser, err := user.Get(r.Context(), claims.Subject)
-	if err != nil {
-		return nil
-	}
-
-	// Get primary email
-	var email string
-	for _, e := range clerkUser.EmailAddresses {
-		if e.ID == *clerkUser.PrimaryEmailAddressID {
-			email = e.EmailAddress
-			break
-		}
-	}
-
-	if email == "" {
-		return nil
-	}
-
-	// Look up user in database
-	u, err := h.userSvc.GetByEmail(r.Context(), email)
-	if err != nil {
-		return nil
-	}
-
-	return u
+	return lookupCurrentUser(r, h.userSvc)
 }
 
 // Get handles GET /media/{id}
@@ -321,11 +406,21 @@ func (h *MediaHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Include the caller's own favorite/rating state
+	if u := h.getCurrentUser(r); u != nil {
+		if state, err := h.svc.GetUserState(r.Context(), item.ID, u.ID); err == nil {
+			item.IsFavorite = state.IsFavorite
+			item.Rating = state.Rating
+		}
+	}
+
+	item.URLs = h.signer.URLs(item)
 	writeJSON(w, http.StatusOK, map[string]any{"item": item})
 }
 
 // Download handles GET /media/{id}/download
-// Serves web-optimized WebP for photos (fast), falls back to preview/original.
+// Serves web-optimized AVIF or WebP for photos (fast), falls back to JPEG/original.
+// Documents are served inline as PDF.
 func (h *MediaHandler) Download(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseMediaID(w, r)
 	if !ok {
@@ -337,8 +432,20 @@ func (h *MediaHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Priority: WebP (fast) > Preview (HEIC converted) > Original
+	serveDownload(w, r, item)
+}
+
+// serveDownload writes the best web-viewable rendition of an item that the
+// client accepts. Photo responses vary by the Accept header.
+func serveDownload(w http.ResponseWriter, r *http.Request, item *models.MediaItem) {
 	fullPath, contentType := resolveDownloadPath(item)
+	switch item.Type {
+	case "photo":
+		fullPath, contentType = negotiatePhotoPath(item, r.Header.Get("Accept"))
+		w.Header().Add("Vary", "Accept")
+	case "document":
+		setDocumentInline(w, item, fullPath)
+	}
 
 	if !fileExists(fullPath) {
 		writeJSON(w, http.StatusNotFound, map[string]any{
@@ -354,12 +461,22 @@ func (h *MediaHandler) Download(w http.ResponseWriter, r *http.Request) {
 }
 
 func resolveDownloadPath(item *models.MediaItem) (fullPath, contentType string) {
+	if item.Type == "document" {
+		return resolveDocumentPath(item)
+	}
+
 	basePath := getMediaBasePath()
 
-	// Prefer web-optimized WebP for photos
+	// Prefer web-optimized WebP for photos, H.264 MP4 for videos and AAC for audio
 	if item.WebPath != "" {
 		webPath := filepath.Join(basePath, item.WebPath)
 		if fileExists(webPath) {
+			switch item.Type {
+			case "video":
+				return webPath, "video/mp4"
+			case "audio":
+				return webPath, "audio/mp4"
+			}
 			return webPath, "image/webp"
 		}
 	}
@@ -373,6 +490,75 @@ func resolveDownloadPath(item *models.MediaItem) (fullPath, contentType string)
 	return filepath.Join(basePath, item.Path), item.MimeType
 }
 
+// negotiatePhotoPath picks the smallest photo rendition the client accepts.
+// Priority: AVIF > WebP > JPEG (HEIC preview, JPEG original, or a resized
+// JPEG rendition) > Original
+func negotiatePhotoPath(item *models.MediaItem, accept string) (fullPath, contentType string) {
+	basePath := getMediaBasePath()
+
+	// Animations are never flattened: animated WebP if accepted, else the original
+	if item.IsAnimated {
+		if item.WebPath != "" && acceptsMediaType(accept, "image/webp") {
+			webPath := filepath.Join(basePath, item.WebPath)
+			if fileExists(webPath) {
+				return webPath, "image/webp"
+			}
+		}
+		return filepath.Join(basePath, item.Path), item.MimeType
+	}
+
+	if item.AvifPath != "" && acceptsMediaType(accept, "image/avif") {
+		avifPath := filepath.Join(basePath, item.AvifPath)
+		if fileExists(avifPath) {
+			return avifPath, "image/avif"
+		}
+	}
+
+	if item.WebPath != "" && acceptsMediaType(accept, "image/webp") {
+		webPath := filepath.Join(basePath, item.WebPath)
+		if fileExists(webPath) {
+			return webPath, "image/webp"
+		}
+	}
+
+	if item.PreviewPath != "" {
+		return filepath.Join(basePath, item.PreviewPath), "image/jpeg"
+	}
+	if item.MimeType == "image/jpeg" {
+		return filepath.Join(basePath, item.Path), item.MimeType
+	}
+
+	// Convert anything else (PNG, WebP uploads, ...) for old clients
+	spec := resizeSpec{Width: WebOptimizedSize, Fit: FitContain, Format: "jpeg"}
+	if jpegPath, err := ensureResizedImage(item, spec); err == nil {
+		return jpegPath, "image/jpeg"
+	}
+
+	return filepath.Join(basePath, item.Path), item.MimeType
+}
+
+// acceptsMediaType reports whether an Accept header explicitly lists the media
+// type with a non-zero quality. Wildcards are ignored on purpose: clients that
+// send only */* get the JPEG fallback.
+func acceptsMediaType(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(typ), mediaType) {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // Original handles GET /media/{id}/original
 func (h *MediaHandler) Original(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseMediaID(w, r)
@@ -385,6 +571,11 @@ func (h *MediaHandler) Original(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	serveOriginal(w, r, item)
+}
+
+// serveOriginal writes the original uploaded file as an attachment
+func serveOriginal(w http.ResponseWriter, r *http.Request, item *models.MediaItem) {
 	fullPath := filepath.Join(getMediaBasePath(), item.Path)
 
 	if !fileExists(fullPath) {
@@ -404,16 +595,65 @@ func (h *MediaHandler) Original(w http.ResponseWriter, r *http.Request) {
 }
 
 // Thumbnail handles GET /media/{id}/thumbnail
+// Items in the trash are served to whoever may restore them.
 func (h *MediaHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 	id, ok := parseMediaID(w, r)
 	if !ok {
 		return
 	}
 
-	item := h.getMediaItem(w, r, id)
-	if item == nil {
+	// The URL doesn't change when the thumbnail does (a new poster frame,
+	// say), so browsers must revalidate it; signed URLs carry a version
+	item, err := h.svc.GetByID(r.Context(), id)
+	cacheControl := revalidateCacheControl
+	if errors.Is(err, models.ErrNotFound) {
+		item, err = h.svc.GetTrashedByID(r.Context(), id)
+		if currentUser := h.getCurrentUser(r); err == nil && (!canView(currentUser, item) || !canManage(currentUser, item.UploaderID)) {
+			err = models.ErrNotFound
+		}
+		cacheControl = "private, no-store"
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "media item not found",
+		})
 		return
 	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+
+	serveThumbnail(w, r, item, cacheControl)
+}
+
+// immutableCacheControl lets browsers keep derivatives whose URL changes
+// whenever the file does
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// revalidateCacheControl lets private caches keep a file but check it is
+// still current before each use
+const revalidateCacheControl = "private, no-cache"
+
+// serveThumbnail writes the item's JPEG thumbnail, or the looping GIF
+// thumbnail of an animation when the request has animated=true.
+// cacheControl is sent with the file, so callers decide how long it is kept.
+func serveThumbnail(w http.ResponseWriter, r *http.Request, item *models.MediaItem, cacheControl string) {
+	if r.URL.Query().Get("animated") == "true" && item.AnimatedThumbnailPath != "" {
+		animPath := filepath.Join(getMediaBasePath(), item.AnimatedThumbnailPath)
+		if fileExists(animPath) {
+			contentType := "image/gif"
+			if filepath.Ext(animPath) == ".mp4" {
+				contentType = "video/mp4" // Video hover preview
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Cache-Control", cacheControl)
+			http.ServeFile(w, r, animPath)
+			return
+		}
+	}
 
 	if item.ThumbnailPath == "" {
 		writeJSON(w, http.StatusNotFound, map[string]any{
@@ -432,37 +672,40 @@ func (h *MediaHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "image/jpeg")
-	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Cache-Control", cacheControl)
 	http.ServeFile(w, r, fullPath)
 }
 
 // Delete handles DELETE /media/{id}
+// Moves the item to the trash; files are removed when the trash is purged.
+// Only the uploader or an admin may delete an item.
 func (h *MediaHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, ok := parseMediaID(w, r)
-	if !ok {
+	currentUser, item := h.getViewableItem(w, r)
+	if item == nil {
 		return
 	}
 
-	item := h.getMediaItem(w, r, id)
-	if item == nil {
+	if !canManage(currentUser, item.UploaderID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "only the uploader or an admin can delete this item",
+		})
 		return
 	}
 
-	if err := h.svc.Delete(r.Context(), id); err != nil {
+	if err := h.svc.Delete(r.Context(), item.ID); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]any{
 			"error": fmt.Sprintf("failed to delete from database: %v", err),
 		})
 		return
 	}
 
-	// Delete files from disk (don't fail if files don't exist)
-	deleteMediaFiles(item)
-
 	writeJSON(w, http.StatusOK, map[string]any{
-		"message": "deleted successfully",
+		"message": "moved to trash",
 	})
 }
 
+// deleteMediaFiles removes an item's original and derivatives from disk
+// (don't fail if files don't exist)
 func deleteMediaFiles(item *models.MediaItem) {
 	basePath := getMediaBasePath()
 	os.Remove(filepath.Join(basePath, item.Path))
@@ -475,6 +718,27 @@ func deleteMediaFiles(item *models.MediaItem) {
 	if item.WebPath != "" {
 		os.Remove(filepath.Join(basePath, item.WebPath))
 	}
+	if item.AvifPath != "" {
+		os.Remove(filepath.Join(basePath, item.AvifPath))
+	}
+	if item.AnimatedThumbnailPath != "" {
+		os.Remove(filepath.Join(basePath, item.AnimatedThumbnailPath))
+	}
+	os.RemoveAll(getResizeCacheDir(item.Path))
+	// Caches from before derivative names kept the extension are no longer read
+	os.RemoveAll(filepath.Join(basePath, ".cache", strings.TrimSuffix(item.Path, filepath.Ext(item.Path))))
+	if item.HLSPath != "" {
+		os.RemoveAll(filepath.Dir(filepath.Join(basePath, item.HLSPath)))
+	}
+}
+
+// canView reports whether the user may see the item: it must belong to the
+// user's household and be either public or uploaded by the user.
+func canView(u *models.User, item *models.MediaItem) bool {
+	if u == nil || u.HouseholdID != item.HouseholdID {
+		return false
+	}
+	return !item.IsPrivate || (item.UploaderID != nil && *item.UploaderID == u.ID)
 }
 
 func fileExists(path string) bool {
@@ -25,6 +25,9 @@ type ImageMetadata struct {
 	FNumber      *float64
 	ExposureTime string
 	FocalLength  *float64
+
+	// Apple content identifier, shared by a Live Photo's still and video
+	ContentIdentifier string
 }
 
 // ExtractImageMetadata extracts EXIF metadata from a JPEG or PNG file
@@ -115,6 +118,10 @@ func ExtractImageMetadata(filePath string) (*ImageMetadata, error) {
 		}
 	}
 
+	if mn, err := x.Get(exif.MakerNote); err == nil {
+		meta.ContentIdentifier = appleContentIdentifier(mn.Val)
+	}
+
 	return meta, nil
 }
 
@@ -151,5 +158,5 @@ func IsHEIC(mimeType string) bool {
 
 // NeedsConversion checks if a file type needs conversion for web display
 func NeedsConversion(mimeType string) bool {
-	return IsHEIC(mimeType)
+	return IsHEIC(mimeType) || IsRAW(mimeType)
 }
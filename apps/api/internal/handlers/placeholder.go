@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// BlurHash component counts; 4x3 suits both landscape and portrait grids
+const (
+	blurHashComponentsX = 4
+	blurHashComponentsY = 3
+)
+
+// placeholderSize is the size images are reduced to before hashing
+const placeholderSize = 32
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// GeneratePlaceholder computes a BlurHash and the dominant color (as
+// "#rrggbb") of an image. It is meant to run on the thumbnail, which is
+// small enough to decode cheaply.
+func GeneratePlaceholder(imagePath string) (blurHash, dominantColor string, err error) {
+	src, err := openImage(imagePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open image: %w", err)
+	}
+
+	small := imaging.Fit(src, placeholderSize, placeholderSize, imaging.Box)
+	return encodeBlurHash(small, blurHashComponentsX, blurHashComponentsY), dominantColorOf(small), nil
+}
+
+// encodeBlurHash implements the BlurHash encoding (https://blurha.sh)
+func encodeBlurHash(img *image.NRGBA, componentsX, componentsY int) string {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	factors := make([][3]float64, 0, componentsX*componentsY)
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			var r, g, b float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					p := img.PixOffset(x, y)
+					r += basis * srgbToLinear(img.Pix[p])
+					g += basis * srgbToLinear(img.Pix[p+1])
+					b += basis * srgbToLinear(img.Pix[p+2])
+				}
+			}
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var sb strings.Builder
+	writeBase83(&sb, (componentsX-1)+(componentsY-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantisedMax := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maximumValue = float64(quantisedMax+1) / 166
+		writeBase83(&sb, quantisedMax, 1)
+	} else {
+		writeBase83(&sb, 0, 1)
+	}
+
+	writeBase83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(max(0, min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		writeBase83(&sb, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+
+	return sb.String()
+}
+
+// dominantColorOf returns the average color of the most common coarse color
+// bucket, which ignores small highlights better than a plain average.
+func dominantColorOf(img *image.NRGBA) string {
+	type bucket struct{ count, r, g, b int }
+	buckets := make(map[int]*bucket)
+	var best *bucket
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := img.PixOffset(x, y)
+			r, g, b := int(img.Pix[p]), int(img.Pix[p+1]), int(img.Pix[p+2])
+
+			key := (r>>5)<<6 | (g>>5)<<3 | b>>5
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.count++
+			bk.r += r
+			bk.g += g
+			bk.b += b
+			if best == nil || bk.count > best.count {
+				best = bk
+			}
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
+
+func writeBase83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func srgbToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+
+	"github.com/disintegration/imaging"
+)
+
+// RAWPreviewQuality is the JPEG quality of previews made from RAW files
+const RAWPreviewQuality = 90
+
+// rawMimeTypes maps RAW file extensions to their MIME types. Most RAW formats
+// are TIFF files underneath and are told apart by extension.
+var rawMimeTypes = map[string]string{
+	".dng": "image/x-adobe-dng",
+	".cr2": "image/x-canon-cr2",
+	".nef": "image/x-nikon-nef",
+	".arw": "image/x-sony-arw",
+}
+
+// IsRAW checks if the MIME type indicates a camera RAW file
+func IsRAW(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	for _, m := range rawMimeTypes {
+		if mimeType == m {
+			return true
+		}
+	}
+	return false
+}
+
+// getRAWPreviewPath returns where the JPEG preview of a RAW file is stored.
+// Previews live outside the original's directory so they can't collide with
+// the camera's own JPEG of a RAW+JPEG pair.
+func getRAWPreviewPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".previews", relativePath, ".jpg")
+}
+
+// ConvertRAWtoJPEG writes a JPEG preview of a RAW file, rotated upright. The
+// largest embedded JPEG preview is used when there is one; otherwise the RAW
+// data is developed with dcraw.
+func ConvertRAWtoJPEG(rawPath, originalRelPath string) (fullPath, relPath string, err error) {
+	fullPath, relPath = getRAWPreviewPath(originalRelPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create preview directory: %w", err)
+	}
+
+	var img image.Image
+	jpegData, orientation, err := extractEmbeddedJPEG(rawPath)
+	if err == nil {
+		img, err = decodeImage(jpegData)
+		if err == nil {
+			img = applyOrientation(img, orientation)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Warning: no usable embedded preview (%v), developing with dcraw\n", err)
+		if img, err = developRAW(rawPath); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := imaging.Save(img, fullPath, imaging.JPEGQuality(RAWPreviewQuality)); err != nil {
+		return "", "", fmt.Errorf("failed to save RAW preview: %w", err)
+	}
+	return fullPath, relPath, nil
+}
+
+// developRAW converts a RAW file with dcraw (camera white balance, 16-bit TIFF
+// on stdout). dcraw applies the orientation itself.
+func developRAW(rawPath string) (image.Image, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("dcraw", "-c", "-w", "-T", rawPath)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("dcraw failed: %v, output: %s", err, stderr.String())
+	}
+
+	img, err := decodeImage(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode dcraw output: %w", err)
+	}
+	return img, nil
+}
+
+// applyOrientation rotates and flips an image according to an EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// TIFF tags used to find embedded previews
+const (
+	tiffTagCompression           = 0x0103
+	tiffTagStripOffsets          = 0x0111
+	tiffTagOrientation           = 0x0112
+	tiffTagStripByteCounts       = 0x0117
+	tiffTagSubIFDs               = 0x014A
+	tiffTagJPEGInterchangeFormat = 0x0201
+	tiffTagJPEGInterchangeLength = 0x0202
+	tiffTagExifIFD               = 0x8769
+)
+
+// maxTIFFIFDs bounds the IFD walk so a malformed file can't loop forever
+const maxTIFFIFDs = 64
+
+// extractEmbeddedJPEG returns the largest baseline JPEG embedded in a
+// TIFF-based RAW file (DNG, CR2, NEF, ARW) and the file's EXIF orientation.
+// Previews are found through JPEGInterchangeFormat tags and through
+// single-strip JPEG-compressed IFDs, in IFD0's chain and in SubIFDs.
+// Lossless-JPEG raw data fails to decode as a baseline JPEG and is skipped.
+func extractEmbeddedJPEG(path string) ([]byte, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	t := &tiffReader{r: f, size: info.Size()}
+
+	var header [8]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return nil, 0, fmt.Errorf("failed to read TIFF header: %w", err)
+	}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, errors.New("not a TIFF-based RAW file")
+	}
+
+	orientation := 0
+	var best []byte
+	bestPixels := 0
+
+	queue := []int64{int64(t.order.Uint32(header[4:8]))}
+	visited := map[int64]bool{}
+	for len(queue) > 0 && len(visited) < maxTIFFIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+
+		ifd, next, err := t.readIFD(offset)
+		if err != nil {
+			continue
+		}
+		if len(visited) == 1 {
+			orientation = int(ifd.value(tiffTagOrientation))
+		}
+		queue = append(queue, next)
+		queue = append(queue, ifd.values(tiffTagSubIFDs)...)
+		if exifIFD := ifd.value(tiffTagExifIFD); exifIFD != 0 {
+			queue = append(queue, exifIFD)
+		}
+
+		var start, length int64
+		switch {
+		case ifd.has(tiffTagJPEGInterchangeFormat):
+			start, length = ifd.value(tiffTagJPEGInterchangeFormat), ifd.value(tiffTagJPEGInterchangeLength)
+		case ifd.has(tiffTagStripOffsets) && (ifd.value(tiffTagCompression) == 6 || ifd.value(tiffTagCompression) == 7):
+			offsets, counts := ifd.values(tiffTagStripOffsets), ifd.values(tiffTagStripByteCounts)
+			if len(offsets) != 1 || len(counts) != 1 {
+				continue
+			}
+			start, length = offsets[0], counts[0]
+		default:
+			continue
+		}
+
+		data, err := t.readJPEG(start, length)
+		if err != nil {
+			continue
+		}
+		cfg, err := jpegConfig(data)
+		if err != nil {
+			continue
+		}
+		if pixels := cfg.Width * cfg.Height; pixels > bestPixels {
+			best, bestPixels = data, pixels
+		}
+	}
+
+	if best == nil {
+		return nil, orientation, errors.New("no embedded JPEG preview")
+	}
+	return best, orientation, nil
+}
+
+func jpegConfig(data []byte) (image.Config, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil && format != "jpeg" {
+		err = fmt.Errorf("unexpected %s preview", format)
+	}
+	return cfg, err
+}
+
+// tiffReader reads IFDs from a TIFF container
+type tiffReader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+// tiffIFD holds the integer values of an IFD's entries by tag
+type tiffIFD map[uint16][]int64
+
+func (ifd tiffIFD) has(tag uint16) bool {
+	return len(ifd[tag]) > 0
+}
+
+func (ifd tiffIFD) value(tag uint16) int64 {
+	if v := ifd[tag]; len(v) > 0 {
+		return v[0]
+	}
+	return 0
+}
+
+func (ifd tiffIFD) values(tag uint16) []int64 {
+	return ifd[tag]
+}
+
+// maxTIFFValues bounds the number of values read per entry
+const maxTIFFValues = 4096
+
+// readIFD reads the SHORT and LONG entries of the IFD at offset and returns
+// them with the offset of the next IFD
+func (t *tiffReader) readIFD(offset int64) (tiffIFD, int64, error) {
+	var countBuf [2]byte
+	if _, err := t.r.ReadAt(countBuf[:], offset); err != nil {
+		return nil, 0, err
+	}
+	count := int64(t.order.Uint16(countBuf[:]))
+
+	entries := make([]byte, count*12+4)
+	if _, err := t.r.ReadAt(entries, offset+2); err != nil {
+		return nil, 0, err
+	}
+
+	ifd := tiffIFD{}
+	for i := int64(0); i < count; i++ {
+		e := entries[i*12 : i*12+12]
+		tag := t.order.Uint16(e[0:2])
+		typ := t.order.Uint16(e[2:4])
+		n := int64(t.order.Uint32(e[4:8]))
+
+		var size int64
+		switch typ {
+		case 3: // SHORT
+			size = 2
+		case 4, 13: // LONG, IFD
+			size = 4
+		default:
+			continue
+		}
+		if n == 0 || n > maxTIFFValues {
+			continue
+		}
+
+		data := e[8:12]
+		if n*size > 4 {
+			valueOffset := int64(t.order.Uint32(e[8:12]))
+			if valueOffset+n*size > t.size {
+				continue
+			}
+			data = make([]byte, n*size)
+			if _, err := t.r.ReadAt(data, valueOffset); err != nil {
+				continue
+			}
+		}
+
+		values := make([]int64, n)
+		for j := range values {
+			if size == 2 {
+				values[j] = int64(t.order.Uint16(data[j*2:]))
+			} else {
+				values[j] = int64(t.order.Uint32(data[j*4:]))
+			}
+		}
+		ifd[tag] = values
+	}
+
+	next := int64(t.order.Uint32(entries[count*12:]))
+	return ifd, next, nil
+}
+
+// readJPEG reads length bytes at offset if they start with a JPEG SOI marker
+func (t *tiffReader) readJPEG(offset, length int64) ([]byte, error) {
+	if offset <= 0 || length < 4 || offset+length > t.size {
+		return nil, errors.New("invalid preview location")
+	}
+	data := make([]byte, length)
+	if _, err := t.r.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+	if data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a JPEG")
+	}
+	return data, nil
+}
+
+// rawPairMaxTimeGap is how far apart the capture times of a RAW file and its
+// camera JPEG may be; cameras write the same timestamp to both
+const rawPairMaxTimeGap = 2 * time.Second
+
+// stackRAWPair stacks a RAW file under the camera JPEG it was shot with (or
+// the JPEG over an already uploaded RAW). Pairs share a file name, uploader
+// and capture time. Failures are logged; the item is left unstacked.
+func (h *MediaHandler) stackRAWPair(ctx context.Context, item *models.MediaItem) {
+	if item.Type != "photo" {
+		return
+	}
+
+	candidates, err := h.svc.FindStackCandidates(ctx, item.HouseholdID, derivedBaseName(item))
+	if err != nil {
+		log.Printf("RAW stack: failed to find pair for %s: %v", item.ID, err)
+		return
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		if c.ID == item.ID || IsRAW(c.MimeType) == IsRAW(item.MimeType) ||
+			!sameUploader(c.UploaderID, item.UploaderID) ||
+			!strings.EqualFold(derivedBaseName(c), derivedBaseName(item)) {
+			continue
+		}
+		if item.TakenAt != nil && c.TakenAt != nil && !takenWithin(item.TakenAt, c.TakenAt, rawPairMaxTimeGap) {
+			continue
+		}
+
+		raw, jpeg := item, c
+		if IsRAW(c.MimeType) {
+			raw, jpeg = c, item
+		}
+		if err := h.svc.StackRAW(ctx, jpeg.ID, raw.ID); err != nil {
+			log.Printf("RAW stack: failed to stack %s under %s: %v", raw.ID, jpeg.ID, err)
+			return
+		}
+		jpeg.StackedRawID = &raw.ID
+		raw.StackParentID = &jpeg.ID
+		return
+	}
+}
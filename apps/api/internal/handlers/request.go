@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// MaxJSONBodySize limits the size of JSON request bodies
+const MaxJSONBodySize = 1 << 20
+
+// parseUUIDParam extracts and validates a UUID from the named URL path parameter.
+// Returns the parsed UUID, or writes an error response and returns false.
+func parseUUIDParam(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	idStr := chi.URLParam(r, name)
+	if idStr == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": fmt.Sprintf("missing %s parameter", name),
+		})
+		return uuid.UUID{}, false
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": fmt.Sprintf("invalid %s parameter", name),
+		})
+		return uuid.UUID{}, false
+	}
+
+	return id, true
+}
+
+// parsePagination reads the page and pageSize query parameters with defaults.
+func parsePagination(r *http.Request) (page, pageSize int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ = strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields.
+// Writes an error response and returns false if the body is invalid.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxJSONBodySize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": fmt.Sprintf("invalid request body: %v", err),
+		})
+		return false
+	}
+	return true
+}
+
+// lookupCurrentUser resolves the database user for the Clerk session in the request.
+// Returns nil if there is no session or the user is not registered.
+func lookupCurrentUser(r *http.Request, userSvc *service.UserService) *models.User {
+	claims, ok := clerk.SessionClaimsFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+
+	// Fetch user from Clerk to get email
+	clerkUser, err := user.Get(r.Context(), claims.Subject)
+	if err != nil {
+		return nil
+	}
+
+	// Get primary email
+	var email string
+	for _, e := range clerkUser.EmailAddresses {
+		if e.ID == *clerkUser.PrimaryEmailAddressID {
+			email = e.EmailAddress
+			break
+		}
+	}
+
+	if email == "" {
+		return nil
+	}
+
+	// Look up user in database
+	u, err := userSvc.GetByEmail(r.Context(), email)
+	if err != nil {
+		return nil
+	}
+
+	return u
+}
+
+// canManage reports whether the user may modify a resource owned by ownerID.
+// Owners and household admins are allowed.
+func canManage(u *models.User, ownerID *uuid.UUID) bool {
+	if u == nil {
+		return false
+	}
+	if u.IsAdmin() {
+		return true
+	}
+	return ownerID != nil && *ownerID == u.ID
+}
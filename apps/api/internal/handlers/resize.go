@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+
+	"storage-api/internal/models"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/sync/singleflight"
+)
+
+// ResizeWidths are the widths (and heights) renditions can be generated at.
+// Requested sizes are rounded up to the next entry so the cache stays bounded.
+var ResizeWidths = []int{160, 320, 480, 640, 960, 1280, 1920, 2560, 3840}
+
+// ResizeQuality is the quality setting for resized JPEG renditions
+const ResizeQuality = 82
+
+// Fit modes for resized renditions
+const (
+	FitContain = "contain" // Scale to fit within w x h, keeping the aspect ratio
+	FitCover   = "cover"   // Scale and crop to fill exactly w x h
+)
+
+// resizeFormats maps the format parameter to file extension and content type
+var resizeFormats = map[string]struct{ ext, contentType string }{
+	"jpeg": {".jpg", "image/jpeg"},
+	"webp": {".webp", "image/webp"},
+	"avif": {".avif", "image/avif"},
+}
+
+var (
+	// resizeGroup de-duplicates concurrent requests for the same rendition
+	resizeGroup singleflight.Group
+
+	// resizeSlots limits how many renditions are generated at once
+	resizeSlots = make(chan struct{}, runtime.NumCPU())
+)
+
+// resizeSpec describes one rendition of an image
+type resizeSpec struct {
+	Width  int
+	Height int // 0 means unconstrained
+	Fit    string
+	Format string
+}
+
+// parseResizeSpec reads and normalizes the w, h, fit and format query parameters
+func parseResizeSpec(r *http.Request) (resizeSpec, error) {
+	q := r.URL.Query()
+	spec := resizeSpec{
+		Fit:    q.Get("fit"),
+		Format: q.Get("format"),
+	}
+	if spec.Fit == "" {
+		spec.Fit = FitContain
+	}
+	if spec.Format == "" {
+		spec.Format = "jpeg"
+	}
+
+	var err error
+	if spec.Width, err = parseResizeDimension(q.Get("w")); err != nil || spec.Width == 0 {
+		return spec, fmt.Errorf("w must be a positive integer")
+	}
+	if spec.Height, err = parseResizeDimension(q.Get("h")); err != nil {
+		return spec, fmt.Errorf("h must be a positive integer")
+	}
+
+	if spec.Fit != FitContain && spec.Fit != FitCover {
+		return spec, fmt.Errorf("fit must be %q or %q", FitContain, FitCover)
+	}
+	if spec.Fit == FitCover && spec.Height == 0 {
+		return spec, fmt.Errorf("fit=%s requires h", FitCover)
+	}
+	if _, ok := resizeFormats[spec.Format]; !ok {
+		return spec, fmt.Errorf("unsupported format %q", spec.Format)
+	}
+	return spec, nil
+}
+
+// parseResizeDimension parses a size and snaps it up to the ResizeWidths
+// allowlist. An empty value returns 0.
+func parseResizeDimension(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid dimension %q", v)
+	}
+	i, _ := slices.BinarySearch(ResizeWidths, n)
+	if i == len(ResizeWidths) {
+		i--
+	}
+	return ResizeWidths[i], nil
+}
+
+// cacheName returns the rendition's file name within the item's cache directory
+func (s resizeSpec) cacheName() string {
+	return fmt.Sprintf("w%d_h%d_%s%s", s.Width, s.Height, s.Fit, resizeFormats[s.Format].ext)
+}
+
+// getResizeCacheDir returns the directory holding an item's cached renditions
+func getResizeCacheDir(relativePath string) string {
+	fullPath, _ := derivativePath(".cache", relativePath, "")
+	return fullPath
+}
+
+// photoSourcePath returns the best file to derive renditions from: the JPEG
+// preview for HEIC photos, otherwise the original.
+func photoSourcePath(item *models.MediaItem) string {
+	if item.PreviewPath != "" {
+		return filepath.Join(getMediaBasePath(), item.PreviewPath)
+	}
+	return filepath.Join(getMediaBasePath(), item.Path)
+}
+
+// Image handles GET /media/{id}/image?w=&h=&fit=&format=
+// Generates (or serves from the disk cache) a resized rendition of a photo.
+func (h *MediaHandler) Image(w http.ResponseWriter, r *http.Request) {
+	_, item := h.getViewableItem(w, r)
+	if item == nil {
+		return
+	}
+
+	if item.Type != "photo" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "resizing is only supported for photos",
+		})
+		return
+	}
+
+	spec, err := parseResizeSpec(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	fullPath, err := ensureResizedImage(item, spec)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to resize image: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", resizeFormats[spec.Format].contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, fullPath)
+}
+
+// ensureResizedImage returns the path of the cached rendition, generating it
+// first if needed. Concurrent calls for the same rendition share one generation.
+func ensureResizedImage(item *models.MediaItem, spec resizeSpec) (string, error) {
+	fullPath := filepath.Join(getResizeCacheDir(item.Path), spec.cacheName())
+	if fileExists(fullPath) {
+		return fullPath, nil
+	}
+
+	srcPath := photoSourcePath(item)
+	_, err, _ := resizeGroup.Do(fullPath, func() (any, error) {
+		if fileExists(fullPath) {
+			return nil, nil
+		}
+
+		resizeSlots <- struct{}{}
+		defer func() { <-resizeSlots }()
+
+		return nil, generateResizedImage(srcPath, fullPath, spec)
+	})
+	if err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// generateResizedImage writes a resized rendition of srcPath to destPath.
+// Images are never scaled up. The file is written to a temporary name and
+// renamed, so readers never see a partial rendition.
+func generateResizedImage(srcPath, destPath string, spec resizeSpec) error {
+	src, err := openImageSRGB(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+
+	switch spec.Fit {
+	case FitCover:
+		// Shrink the crop box (keeping its aspect ratio) rather than upscale
+		width, height := spec.Width, spec.Height
+		b := src.Bounds()
+		if scale := min(float64(b.Dx())/float64(width), float64(b.Dy())/float64(height)); scale < 1 {
+			width = max(1, int(float64(width)*scale))
+			height = max(1, int(float64(height)*scale))
+		}
+		src = imaging.Fill(src, width, height, imaging.Center, imaging.Lanczos)
+	default:
+		height := spec.Height
+		if height == 0 {
+			height = src.Bounds().Dy()
+		}
+		src = imaging.Fit(src, spec.Width, height, imaging.Lanczos)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tempPath := destPath + ".temp" + filepath.Ext(destPath)
+	defer os.Remove(tempPath)
+
+	switch spec.Format {
+	case "webp", "avif":
+		tempPNG := destPath + ".temp.png"
+		defer os.Remove(tempPNG)
+		if err := imaging.Save(src, tempPNG); err != nil {
+			return fmt.Errorf("failed to save temp image: %w", err)
+		}
+		convert := convertToWebP
+		if spec.Format == "avif" {
+			convert = convertToAVIF
+		}
+		if err := convert(tempPNG, tempPath); err != nil {
+			return err
+		}
+	default:
+		if err := imaging.Save(src, tempPath, imaging.JPEGQuality(ResizeQuality)); err != nil {
+			return fmt.Errorf("failed to save resized image: %w", err)
+		}
+	}
+
+	return os.Rename(tempPath, destPath)
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// SharePasswordHeader carries the password for password-protected share links.
+// It is only checked by GET /share/{token}, which then grants access to the
+// link's media through shareAccessCookie and the shareAccessParam query value.
+const SharePasswordHeader = "X-Share-Password"
+
+// A share access token (see MediaURLSigner.ShareAccessToken) is sent as a
+// cookie scoped to the link, and appended to the media URLs in the response
+// for clients on another origin, where the cookie isn't sent with <img> tags
+const (
+	shareAccessCookie = "share_access"
+	shareAccessParam  = "access"
+)
+
+// shareCacheControl keeps shared responses out of shared and browser caches,
+// so revoking or expiring a link takes effect immediately
+const shareCacheControl = "private, no-store"
+
+type ShareHandler struct {
+	svc      *service.ShareService
+	mediaSvc *service.MediaService
+	userSvc  *service.UserService
+	signer   *service.MediaURLSigner
+}
+
+func NewShareHandler(svc *service.ShareService, mediaSvc *service.MediaService, userSvc *service.UserService, signer *service.MediaURLSigner) *ShareHandler {
+	return &ShareHandler{svc: svc, mediaSvc: mediaSvc, userSvc: userSvc, signer: signer}
+}
+
+// createShareRequest is the request body for POST /shares
+type createShareRequest struct {
+	MediaIDs      []uuid.UUID `json:"mediaIds"`
+	Title         string      `json:"title,omitempty"`
+	Password      string      `json:"password,omitempty"`
+	ExpiresAt     *time.Time  `json:"expiresAt,omitempty"`
+	AllowOriginal bool        `json:"allowOriginal"`
+}
+
+// sharedItem is the public view of a shared media item.
+// It deliberately leaves out paths, uploader, location and camera details.
+type sharedItem struct {
+	ID           uuid.UUID  `json:"id"`
+	Type         string     `json:"type"`
+	MimeType     string     `json:"mimeType,omitempty"`
+	TakenAt      *time.Time `json:"takenAt,omitempty"`
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	DurationSec  int        `json:"durationSec,omitempty"`
+	ThumbnailURL string     `json:"thumbnailUrl"`
+	DownloadURL  string     `json:"downloadUrl"`
+	OriginalURL  string     `json:"originalUrl,omitempty"`
+}
+
+// getHouseholdUser resolves the current user and checks they belong to the
+// X-Household-ID household. Writes an error response and returns nil otherwise.
+func (h *ShareHandler) getHouseholdUser(w http.ResponseWriter, r *http.Request) *models.User {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return nil
+	}
+
+	currentUser := lookupCurrentUser(r, h.userSvc)
+	if currentUser == nil || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return nil
+	}
+	return currentUser
+}
+
+// Create handles POST /shares
+// Every item must be visible to the caller.
+func (h *ShareHandler) Create(w http.ResponseWriter, r *http.Request) {
+	currentUser := h.getHouseholdUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	var req createShareRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ids := uniqueIDs(req.MediaIDs)
+	if len(ids) == 0 || len(ids) > models.MaxShareItems {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": fmt.Sprintf("mediaIds must contain between 1 and %d items", models.MaxShareItems),
+		})
+		return
+	}
+
+	items, err := h.mediaSvc.GetByIDs(r.Context(), ids)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+
+	visible := make(map[uuid.UUID]bool, len(items))
+	for i := range items {
+		if canView(currentUser, &items[i]) {
+			visible[items[i].ID] = true
+		}
+	}
+	for _, id := range ids {
+		if !visible[id] {
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error": fmt.Sprintf("media item %s not found", id),
+			})
+			return
+		}
+	}
+
+	link := &models.ShareLink{
+		HouseholdID:   currentUser.HouseholdID,
+		CreatedBy:     &currentUser.ID,
+		Title:         req.Title,
+		ExpiresAt:     req.ExpiresAt,
+		AllowOriginal: req.AllowOriginal,
+		MediaIDs:      ids,
+	}
+
+	if err := h.svc.Create(r.Context(), link, req.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidInput) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to create share link: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{"share": link})
+}
+
+// List handles GET /shares
+// Admins see every link in the household; members see their own.
+func (h *ShareHandler) List(w http.ResponseWriter, r *http.Request) {
+	currentUser := h.getHouseholdUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	var createdBy *uuid.UUID
+	if !currentUser.IsAdmin() {
+		createdBy = &currentUser.ID
+	}
+
+	links, err := h.svc.List(r.Context(), currentUser.HouseholdID, createdBy)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list share links: %v", err),
+		})
+		return
+	}
+
+	if links == nil {
+		links = []models.ShareLink{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"shares": links})
+}
+
+// Revoke handles DELETE /shares/{id}
+func (h *ShareHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	currentUser := h.getHouseholdUser(w, r)
+	if currentUser == nil {
+		return
+	}
+
+	id, ok := parseUUIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	link, err := h.svc.GetByID(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get share link: %v", err),
+		})
+		return
+	}
+	if err != nil || link.HouseholdID != currentUser.HouseholdID {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "share link not found",
+		})
+		return
+	}
+
+	if !canManage(currentUser, link.CreatedBy) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "only the creator or an admin can revoke this link",
+		})
+		return
+	}
+
+	if err := h.svc.Revoke(r.Context(), link.ID); err != nil && !errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to revoke share link: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "share link revoked",
+	})
+}
+
+// resolveLink looks up the {token} link.
+// Writes an error response and returns nil if the link cannot be used.
+func (h *ShareHandler) resolveLink(w http.ResponseWriter, r *http.Request) *models.ShareLink {
+	w.Header().Set("Cache-Control", shareCacheControl)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	link, err := h.svc.Resolve(r.Context(), chi.URLParam(r, "token"))
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "share link not found or expired",
+		})
+		return nil
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get share link: %v", err),
+		})
+		return nil
+	}
+	return link
+}
+
+// hasShareAccess reports whether the request carries a valid access token
+// for the link, in the cookie or the query string
+func (h *ShareHandler) hasShareAccess(r *http.Request, link *models.ShareLink) bool {
+	if token := r.URL.Query().Get(shareAccessParam); token != "" &&
+		h.signer.VerifyShareAccess(link.ID, token) == nil {
+		return true
+	}
+	cookie, err := r.Cookie(shareAccessCookie)
+	return err == nil && h.signer.VerifyShareAccess(link.ID, cookie.Value) == nil
+}
+
+// writePasswordRequired writes the 401 that tells clients to ask for the
+// link's password
+func writePasswordRequired(w http.ResponseWriter) {
+	writeJSON(w, http.StatusUnauthorized, map[string]any{
+		"error":            "password required",
+		"passwordRequired": true,
+	})
+}
+
+// authorizeLink checks the password of a protected link, unless the request
+// already has access, and grants access for the link's media. Returns the
+// access token to add to media URLs ("" for links without a password), or
+// false after writing an error response.
+func (h *ShareHandler) authorizeLink(w http.ResponseWriter, r *http.Request, link *models.ShareLink) (string, bool) {
+	if !link.HasPassword {
+		return "", true
+	}
+
+	if !h.hasShareAccess(r, link) {
+		err := h.svc.CheckPassword(link, r.Header.Get(SharePasswordHeader))
+		switch {
+		case errors.Is(err, models.ErrPasswordRequired):
+			writePasswordRequired(w)
+			return "", false
+		case errors.Is(err, models.ErrTooManyAttempts):
+			w.Header().Set("Retry-After", "900")
+			writeJSON(w, http.StatusTooManyRequests, map[string]any{
+				"error": "too many wrong passwords, try again later",
+			})
+			return "", false
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]any{
+				"error": fmt.Sprintf("failed to check password: %v", err),
+			})
+			return "", false
+		}
+	}
+
+	token, exp := h.signer.ShareAccessToken(link.ID)
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareAccessCookie,
+		Value:    token,
+		Path:     "/share/" + link.Token,
+		Expires:  exp,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token, true
+}
+
+// GetShared handles GET /share/{token} (public)
+// Returns the link's items in the order they were shared. Items that were
+// deleted since are left out.
+func (h *ShareHandler) GetShared(w http.ResponseWriter, r *http.Request) {
+	link := h.resolveLink(w, r)
+	if link == nil {
+		return
+	}
+	access, ok := h.authorizeLink(w, r, link)
+	if !ok {
+		return
+	}
+	query := ""
+	if access != "" {
+		query = "?" + url.Values{shareAccessParam: {access}}.Encode()
+	}
+
+	items, err := h.mediaSvc.GetByIDs(r.Context(), link.MediaIDs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+
+	byID := make(map[uuid.UUID]*models.MediaItem, len(items))
+	for i := range items {
+		if items[i].HouseholdID == link.HouseholdID {
+			byID[items[i].ID] = &items[i]
+		}
+	}
+
+	shared := []sharedItem{}
+	for _, id := range link.MediaIDs {
+		item, ok := byID[id]
+		if !ok {
+			continue
+		}
+		base := fmt.Sprintf("/share/%s/media/%s", link.Token, item.ID)
+		si := sharedItem{
+			ID:           item.ID,
+			Type:         item.Type,
+			MimeType:     item.MimeType,
+			TakenAt:      item.TakenAt,
+			Width:        item.Width,
+			Height:       item.Height,
+			DurationSec:  item.DurationSec,
+			ThumbnailURL: base + "/thumbnail" + query,
+			DownloadURL:  base + "/download" + query,
+		}
+		if link.AllowOriginal {
+			si.OriginalURL = base + "/original" + query
+		}
+		shared = append(shared, si)
+	}
+
+	if err := h.svc.RecordView(r.Context(), link.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to record view: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"title":         link.Title,
+		"expiresAt":     link.ExpiresAt,
+		"allowOriginal": link.AllowOriginal,
+		"items":         shared,
+	})
+}
+
+// getSharedItem resolves the link and the {id} item, and checks the item is
+// part of the link. Protected links need the access token granted by
+// GetShared; the password itself is not accepted here. Writes an error
+// response and returns nils otherwise.
+func (h *ShareHandler) getSharedItem(w http.ResponseWriter, r *http.Request) (*models.ShareLink, *models.MediaItem) {
+	link := h.resolveLink(w, r)
+	if link == nil {
+		return nil, nil
+	}
+	if link.HasPassword && !h.hasShareAccess(r, link) {
+		writePasswordRequired(w)
+		return nil, nil
+	}
+
+	id, ok := parseMediaID(w, r)
+	if !ok {
+		return nil, nil
+	}
+
+	if !slices.Contains(link.MediaIDs, id) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "media item not found",
+		})
+		return nil, nil
+	}
+
+	item := fetchMediaItem(w, r, h.mediaSvc, id)
+	if item == nil {
+		return nil, nil
+	}
+	if item.HouseholdID != link.HouseholdID {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "media item not found",
+		})
+		return nil, nil
+	}
+
+	return link, item
+}
+
+// Thumbnail handles GET /share/{token}/media/{id}/thumbnail (public)
+func (h *ShareHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	_, item := h.getSharedItem(w, r)
+	if item == nil {
+		return
+	}
+	serveThumbnail(w, r, item, shareCacheControl)
+}
+
+// Download handles GET /share/{token}/media/{id}/download (public)
+// Serves the web-viewable rendition.
+func (h *ShareHandler) Download(w http.ResponseWriter, r *http.Request) {
+	_, item := h.getSharedItem(w, r)
+	if item == nil {
+		return
+	}
+	serveDownload(w, r, item)
+}
+
+// Original handles GET /share/{token}/media/{id}/original (public)
+// Only available when the link allows original downloads.
+func (h *ShareHandler) Original(w http.ResponseWriter, r *http.Request) {
+	link, item := h.getSharedItem(w, r)
+	if item == nil {
+		return
+	}
+
+	if !link.AllowOriginal {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "original downloads are disabled for this link",
+		})
+		return
+	}
+
+	serveOriginal(w, r, item)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"storage-api/internal/models"
+	"storage-api/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// signItems sets signed URLs on every item in a response
+func signItems(signer *service.MediaURLSigner, items []models.MediaItem) {
+	for i := range items {
+		items[i].URLs = signer.URLs(&items[i])
+	}
+}
+
+// Signed handles GET /signed/media/{id}/{kind} (public)
+// The URL's signature stands in for the Authorization header, so the route
+// works in plain <img> and <video> tags.
+func (h *MediaHandler) Signed(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMediaID(w, r)
+	if !ok {
+		return
+	}
+
+	kind := chi.URLParam(r, "kind")
+	if err := h.signer.Verify(id, kind, r.URL.Query()); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidSignature) {
+			status = http.StatusForbidden
+		}
+		writeJSON(w, status, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if kind == models.MediaKindTrashThumbnail {
+		h.serveTrashThumbnail(w, r, id)
+		return
+	}
+
+	item := h.getMediaItem(w, r, id)
+	if item == nil {
+		return
+	}
+
+	switch kind {
+	case models.MediaKindThumbnail:
+		serveThumbnail(w, r, item, immutableCacheControl)
+	case models.MediaKindDownload:
+		serveDownload(w, r, item)
+	case models.MediaKindOriginal:
+		serveOriginal(w, r, item)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "unknown media kind",
+		})
+	}
+}
+
+// serveTrashThumbnail serves the thumbnail of an item in the trash. The
+// signature was only issued to someone allowed to manage the item (see
+// ListTrash). Not cached, since the item may be purged at any time.
+func (h *MediaHandler) serveTrashThumbnail(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	item, err := h.svc.GetTrashedByID(r.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "media item not found in trash",
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+	serveThumbnail(w, r, item, "private, no-store")
+}
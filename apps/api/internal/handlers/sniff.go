@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// sniffLen is how much of an upload is read to detect its type
+const sniffLen = 4096
+
+// DefaultMaxImagePixels is the largest image (width × height) decoded by
+// default. A decoded pixel takes 4 bytes or more, so this bounds memory per
+// image at about 400MB.
+const DefaultMaxImagePixels = 100_000_000
+
+// maxImagePixels is the largest image decoded; zero disables the check
+var maxImagePixels = DefaultMaxImagePixels
+
+// Upload type errors
+var (
+	errUnrecognizedType = errors.New("unrecognized file type")
+	errTypeMismatch     = errors.New("file content does not match its declared type")
+)
+
+// ErrImageTooLarge is returned for images with more than maxImagePixels pixels
+var ErrImageTooLarge = errors.New("image has too many pixels")
+
+// SetMaxImagePixels sets the largest image (width × height) that is decoded.
+// Zero disables the check.
+func SetMaxImagePixels(n int) {
+	maxImagePixels = n
+}
+
+// DefaultUploadTypes are the MIME types accepted for upload unless configured
+// otherwise: everything the processing pipeline handles
+var DefaultUploadTypes = slices.Concat(
+	[]string{
+		"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp", "image/tiff",
+		"image/heic", "image/heif",
+		"video/mp4", "video/quicktime", "video/webm", "video/x-matroska",
+		"video/x-msvideo", "video/mp2t", "video/3gpp",
+	},
+	slices.Collect(maps.Values(rawMimeTypes)),
+	slices.Collect(maps.Values(audioMimeTypes)),
+	slices.Collect(maps.Values(documentMimeTypes)),
+)
+
+// isobmffBrands maps ISO base media (MP4/QuickTime/HEIF) major brands to MIME
+// types. Other brands are plain MP4.
+var isobmffBrands = map[string]string{
+	"heic": "image/heic", "heix": "image/heic", "heim": "image/heic", "heis": "image/heic",
+	"hevc": "image/heic", "hevx": "image/heic", "hevm": "image/heic", "hevs": "image/heic",
+	"mif1": "image/heif", "msf1": "image/heif",
+	"avif": "image/avif", "avis": "image/avif",
+	"crx ": "image/x-canon-cr3",
+	"qt  ": "video/quicktime",
+	"M4A ": "audio/mp4", "M4B ": "audio/mp4", "M4P ": "audio/mp4",
+	"3gp4": "video/3gpp", "3gp5": "video/3gpp", "3gp6": "video/3gpp", "3g2a": "video/3gpp",
+}
+
+// avContainers can hold audio, video or both. For these the declared category
+// decides whether a file is processed as audio or video.
+var avContainers = []string{
+	"video/mp4", "audio/mp4", "video/quicktime", "video/3gpp",
+	"video/webm", "video/x-matroska", "audio/ogg",
+}
+
+// Office formats are ZIP (OOXML, OpenDocument) or OLE2 (legacy Microsoft)
+// containers; which one is only told apart by the extension
+var (
+	zipDocumentExts = []string{".docx", ".xlsx", ".pptx", ".odt", ".ods", ".odp"}
+	oleDocumentExts = []string{".doc", ".xls", ".ppt"}
+)
+
+// sniffMimeType detects a file's MIME type from its first bytes. The file
+// name only disambiguates formats that share a container (TIFF-based RAW,
+// office documents, M4A). Returns "" if the type isn't recognized.
+func sniffMimeType(head []byte, filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	at := func(offset int, sig string) bool {
+		return len(head) >= offset+len(sig) && string(head[offset:offset+len(sig)]) == sig
+	}
+
+	switch {
+	case at(0, "\xFF\xD8\xFF"):
+		return "image/jpeg"
+	case at(0, "\x89PNG\r\n\x1A\n"):
+		return "image/png"
+	case at(0, "GIF87a"), at(0, "GIF89a"):
+		return "image/gif"
+	case at(0, "BM") && len(head) >= 18 && slices.Contains([]byte{12, 40, 52, 56, 108, 124}, head[14]) &&
+		at(15, "\x00\x00\x00"):
+		return "image/bmp" // Followed by a known DIB header size
+	case at(0, "RIFF") && at(8, "WEBP"):
+		return "image/webp"
+	case at(0, "RIFF") && at(8, "WAVE"):
+		return "audio/wav"
+	case at(0, "RIFF") && at(8, "AVI "):
+		return "video/x-msvideo"
+
+	case at(0, "II*\x00"), at(0, "MM\x00*"):
+		if at(8, "CR") {
+			return "image/x-canon-cr2"
+		}
+		if rawType := rawMimeTypes[ext]; rawType != "" {
+			return rawType
+		}
+		return "image/tiff"
+
+	case at(4, "ftyp"):
+		brand := ""
+		if len(head) >= 12 {
+			brand = string(head[8:12])
+		}
+		t, ok := isobmffBrands[brand]
+		if t == "image/heif" {
+			// Generic HEIF; the compatible brands say whether it's HEVC or AV1
+			switch compat := isobmffCompatibleBrands(head); {
+			case slices.Contains(compat, "avif"):
+				return "image/avif"
+			case slices.Contains(compat, "heic"):
+				return "image/heic"
+			}
+		}
+		if ok {
+			return t
+		}
+		if ext == ".m4a" {
+			return "audio/mp4" // Some encoders use a generic brand
+		}
+		return "video/mp4"
+	case at(4, "moov"), at(4, "mdat"), at(4, "wide"), at(4, "free"):
+		return "video/quicktime" // Old QuickTime files without ftyp
+
+	case at(0, "\x1A\x45\xDF\xA3"):
+		if bytes.Contains(head[:min(len(head), 64)], []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	case at(0, "\x47") && at(188, "\x47"), at(4, "\x47") && at(196, "\x47"):
+		return "video/mp2t" // MPEG-TS and AVCHD's M2TS
+
+	case at(0, "OggS"):
+		return "audio/ogg"
+	case at(0, "fLaC"):
+		return "audio/flac"
+	case at(0, "ID3"):
+		return "audio/mpeg"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0:
+		return "audio/aac" // ADTS
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return "audio/mpeg" // MP3 frame without ID3 tag
+
+	case at(0, "PK\x03\x04"):
+		if slices.Contains(zipDocumentExts, ext) {
+			return documentMimeTypes[ext]
+		}
+	case at(0, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"):
+		if slices.Contains(oleDocumentExts, ext) {
+			return documentMimeTypes[ext]
+		}
+	case at(0, "{\\rtf"):
+		return "application/rtf"
+	case bytes.Contains(head[:min(len(head), 1024)], []byte("%PDF-")):
+		return "application/pdf" // May follow a few bytes of junk
+	}
+	return ""
+}
+
+// isobmffCompatibleBrands lists the compatible brands of the ftyp box at the
+// start of head
+func isobmffCompatibleBrands(head []byte) []string {
+	size := int(binary.BigEndian.Uint32(head))
+	var brands []string
+	for i := 16; i+4 <= min(size, len(head)); i += 4 {
+		brands = append(brands, string(head[i:i+4]))
+	}
+	return brands
+}
+
+// detectUploadType determines an upload's MIME type from its first bytes.
+// When the client-supplied Content-Type names a kind of media (photo, video,
+// audio, document), it must agree with the content.
+func detectUploadType(head []byte, filename, contentType string) (string, error) {
+	sniffed := sniffMimeType(head, filename)
+	if sniffed == "" {
+		return "", errUnrecognizedType
+	}
+
+	claimed := claimedMediaType(contentType)
+	if claimed == "" || claimed == detectMediaType(sniffed) {
+		return sniffed, nil
+	}
+	if slices.Contains(avContainers, sniffed) && (claimed == "audio" || claimed == "video") {
+		_, subtype, _ := strings.Cut(sniffed, "/")
+		return claimed + "/" + subtype, nil
+	}
+	return "", fmt.Errorf("%w (content is %s)", errTypeMismatch, sniffed)
+}
+
+// readSniffHead reads the start of an upload for sniffMimeType
+func readSniffHead(f io.ReaderAt) ([]byte, error) {
+	head := make([]byte, sniffLen)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// uploadTypeAllowed reports whether mimeType matches an entry of allowed.
+// Entries may be a whole category, as in "image/*".
+func uploadTypeAllowed(mimeType string, allowed []string) bool {
+	category, _, _ := strings.Cut(mimeType, "/")
+	for _, a := range allowed {
+		if a == mimeType || a == category+"/*" || a == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// claimedMediaType returns the media type implied by a client-supplied
+// Content-Type, or "" if it doesn't imply one (e.g. application/octet-stream)
+func claimedMediaType(contentType string) string {
+	mimeType, _, _ := strings.Cut(contentType, ";")
+	return detectMediaType(strings.ToLower(strings.TrimSpace(mimeType)))
+}
+
+// checkImagePixels reads an image's dimensions from its header and returns
+// ErrImageTooLarge if decoding it would exceed maxImagePixels. Formats Go
+// can't decode pass, since imaging can't open them either.
+func checkImagePixels(r io.Reader) error {
+	if maxImagePixels <= 0 {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > int64(maxImagePixels) {
+		return fmt.Errorf("%w: %dx%d is over the limit of %d", ErrImageTooLarge, cfg.Width, cfg.Height, maxImagePixels)
+	}
+	return nil
+}
+
+// openImage is imaging.Open with the pixel limit checked first, so an image
+// that decompresses to gigabytes is never decoded
+func openImage(path string, opts ...imaging.DecodeOption) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = checkImagePixels(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return imaging.Open(path, opts...)
+}
+
+// decodeImage is imaging.Decode with the pixel limit checked first
+func decodeImage(data []byte, opts ...imaging.DecodeOption) (image.Image, error) {
+	if err := checkImagePixels(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return imaging.Decode(bytes.NewReader(data), opts...)
+}
+
+// decodeGIF is gif.DecodeAll with the pixel limit applied to all frames
+// together, since every frame is kept in memory. The frames are counted by
+// walking the file's blocks first, without decoding anything.
+func decodeGIF(path string) (*gif.GIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if maxImagePixels > 0 {
+		info, framePixels, err := scanGIF(bufio.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+		if framePixels > int64(maxImagePixels) {
+			return nil, fmt.Errorf("%w: %d frames of %d pixels in all are over the limit of %d",
+				ErrImageTooLarge, info.FrameCount, framePixels, maxImagePixels)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return gif.DecodeAll(bufio.NewReader(f))
+}
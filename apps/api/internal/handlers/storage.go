@@ -28,18 +28,31 @@ const WebOptimizedSize = 2400
 // WebOptimizedQuality is the quality setting for web-optimized WebP images
 const WebOptimizedQuality = 85
 
+// AVIFQuality is the quality setting for web-optimized AVIF images
+const AVIFQuality = 60
+
 // SavedFile contains metadata about a successfully saved file.
 type SavedFile struct {
 	RelativePath        string
 	FullPath            string
 	Size                int64
 	SHA256              string
-	PreviewRelativePath string         // Path to JPEG preview (for HEIC files)
-	PreviewFullPath     string         // Full path to preview
-	ThumbnailRelPath    string         // Path to thumbnail (relative)
-	ThumbnailFullPath   string         // Full path to thumbnail
-	WebRelPath          string         // Path to web-optimized WebP (relative)
-	WebFullPath         string         // Full path to web-optimized WebP
+	PreviewRelativePath string            // Path to JPEG preview (HEIC and RAW) or PDF preview (office documents)
+	PreviewFullPath     string            // Full path to preview
+	ThumbnailRelPath    string            // Path to thumbnail (relative)
+	ThumbnailFullPath   string            // Full path to thumbnail
+	WebRelPath          string            // Path to web-optimized WebP, MP4 or M4A (relative)
+	WebFullPath         string            // Full path to web-optimized WebP, MP4 or M4A
+	AvifRelPath         string            // Path to web-optimized AVIF (relative)
+	AvifFullPath        string            // Full path to web-optimized AVIF
+	BlurHash            string            // BlurHash of the thumbnail
+	DominantColor       string            // Dominant color of the thumbnail ("#rrggbb")
+	Animation           *AnimationInfo    // Set for animated GIF/WebP files
+	Video               *VideoMetadata    // Set for videos
+	Document            *DocumentMetadata // Set for documents
+	Audio               *AudioMetadata    // Set for audio files
+	AnimThumbRelPath    string            // Path to looping GIF thumbnail or video hover preview (relative)
+	AnimThumbFullPath   string            // Full path to looping GIF thumbnail or video hover preview
 	Metadata            *ImageMetadata
 }
 
@@ -100,10 +113,16 @@ func processMediaFile(result *SavedFile, fullPath, relativePath, mimeType string
 	switch {
 	case IsHEIC(mimeType):
 		processHEICFile(result, fullPath, relativePath)
+	case IsRAW(mimeType):
+		processRAWFile(result, fullPath, relativePath)
 	case strings.HasPrefix(mimeType, "image/"):
 		processImageFile(result, fullPath, relativePath)
 	case strings.HasPrefix(mimeType, "video/"):
 		processVideoFile(result, fullPath, relativePath)
+	case IsAudio(mimeType):
+		processAudioFile(result, fullPath, relativePath)
+	case IsDocument(mimeType):
+		processDocumentFile(result, fullPath, relativePath, mimeType)
 	}
 }
 
@@ -124,17 +143,73 @@ func processHEICFile(result *SavedFile, fullPath, relativePath string) {
 	if thumbFull, thumbRel, err := GenerateImageThumbnail(previewPath, relativePath); err == nil {
 		result.ThumbnailFullPath = thumbFull
 		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
 	} else {
 		fmt.Printf("Warning: failed to generate thumbnail for HEIC: %v\n", err)
 	}
 
-	// Generate WebP from the JPEG preview
+	// Generate WebP and AVIF from the JPEG preview
 	if webFull, webRel, err := GenerateWebOptimizedImage(previewPath, relativePath); err == nil {
 		result.WebFullPath = webFull
 		result.WebRelPath = webRel
 	} else {
 		fmt.Printf("Warning: failed to generate web-optimized image for HEIC: %v\n", err)
 	}
+
+	if avifFull, avifRel, err := GenerateAVIFImage(previewPath, relativePath); err == nil {
+		result.AvifFullPath = avifFull
+		result.AvifRelPath = avifRel
+	} else {
+		fmt.Printf("Warning: failed to generate AVIF image for HEIC: %v\n", err)
+	}
+}
+
+// processRAWFile derives everything from a JPEG preview of the RAW file.
+// EXIF is read from the RAW file itself, which is a TIFF container.
+func processRAWFile(result *SavedFile, fullPath, relativePath string) {
+	if meta, err := ExtractImageMetadata(fullPath); err == nil {
+		result.Metadata = meta
+	}
+
+	previewPath, previewRel, err := ConvertRAWtoJPEG(fullPath, relativePath)
+	if err != nil {
+		fmt.Printf("Warning: failed to convert RAW to JPEG: %v\n", err)
+		return
+	}
+	result.PreviewFullPath = previewPath
+	result.PreviewRelativePath = previewRel
+
+	// RAW EXIF often lacks pixel dimensions; use the upright preview's
+	if result.Metadata != nil && (result.Metadata.Width == 0 || result.Metadata.Height == 0) {
+		if f, err := os.Open(previewPath); err == nil {
+			if cfg, _, err := image.DecodeConfig(f); err == nil {
+				result.Metadata.Width, result.Metadata.Height = cfg.Width, cfg.Height
+			}
+			f.Close()
+		}
+	}
+
+	if thumbFull, thumbRel, err := GenerateImageThumbnail(previewPath, relativePath); err == nil {
+		result.ThumbnailFullPath = thumbFull
+		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
+	} else {
+		fmt.Printf("Warning: failed to generate thumbnail for RAW: %v\n", err)
+	}
+
+	if webFull, webRel, err := GenerateWebOptimizedImage(previewPath, relativePath); err == nil {
+		result.WebFullPath = webFull
+		result.WebRelPath = webRel
+	} else {
+		fmt.Printf("Warning: failed to generate web-optimized image for RAW: %v\n", err)
+	}
+
+	if avifFull, avifRel, err := GenerateAVIFImage(previewPath, relativePath); err == nil {
+		result.AvifFullPath = avifFull
+		result.AvifRelPath = avifRel
+	} else {
+		fmt.Printf("Warning: failed to generate AVIF image for RAW: %v\n", err)
+	}
 }
 
 func processImageFile(result *SavedFile, fullPath, relativePath string) {
@@ -142,9 +217,17 @@ func processImageFile(result *SavedFile, fullPath, relativePath string) {
 		result.Metadata = meta
 	}
 
+	if anim, err := DetectAnimation(fullPath); err == nil && anim != nil {
+		processAnimatedImage(result, anim, fullPath, relativePath)
+		return
+	} else if err != nil {
+		fmt.Printf("Warning: failed to detect animation: %v\n", err)
+	}
+
 	if thumbFull, thumbRel, err := GenerateImageThumbnail(fullPath, relativePath); err == nil {
 		result.ThumbnailFullPath = thumbFull
 		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
 	} else {
 		fmt.Printf("Warning: failed to generate thumbnail: %v\n", err)
 	}
@@ -155,15 +238,98 @@ func processImageFile(result *SavedFile, fullPath, relativePath string) {
 	} else {
 		fmt.Printf("Warning: failed to generate web-optimized image: %v\n", err)
 	}
+
+	if avifFull, avifRel, err := GenerateAVIFImage(fullPath, relativePath); err == nil {
+		result.AvifFullPath = avifFull
+		result.AvifRelPath = avifRel
+	} else {
+		fmt.Printf("Warning: failed to generate AVIF image: %v\n", err)
+	}
+}
+
+// processAnimatedImage keeps the animation in derivatives where possible.
+// The regular thumbnail is a still of the first frame and every animation
+// gets a looping GIF thumbnail. GIFs also get an animated WebP; animated WebP
+// originals are already web-friendly and are served as-is.
+func processAnimatedImage(result *SavedFile, anim *AnimationInfo, fullPath, relativePath string) {
+	result.Animation = anim
+	if result.Metadata == nil {
+		result.Metadata = &ImageMetadata{}
+	}
+	if result.Metadata.Width == 0 {
+		result.Metadata.Width = anim.Width
+		result.Metadata.Height = anim.Height
+	}
+
+	// Go's WebP decoder only reads still images, so the first frame of an
+	// animated WebP is composited here
+	generateThumbnail := GenerateImageThumbnail
+	if anim.Format == "webp" {
+		generateThumbnail = GenerateFirstFrameThumbnail
+	}
+	if thumbFull, thumbRel, err := generateThumbnail(fullPath, relativePath); err == nil {
+		result.ThumbnailFullPath = thumbFull
+		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
+	} else {
+		fmt.Printf("Warning: failed to generate thumbnail: %v\n", err)
+	}
+
+	if animFull, animRel, err := GenerateAnimatedThumbnail(fullPath, relativePath, anim); err == nil {
+		result.AnimThumbFullPath = animFull
+		result.AnimThumbRelPath = animRel
+	} else {
+		fmt.Printf("Warning: failed to generate animated thumbnail: %v\n", err)
+	}
+
+	if anim.Format != "gif" {
+		return
+	}
+
+	if webFull, webRel, err := GenerateAnimatedWebP(fullPath, relativePath); err == nil {
+		result.WebFullPath = webFull
+		result.WebRelPath = webRel
+	} else {
+		fmt.Printf("Warning: failed to generate animated WebP: %v\n", err)
+	}
 }
 
 func processVideoFile(result *SavedFile, fullPath, relativePath string) {
+	if meta, err := ExtractVideoMetadata(fullPath); err == nil {
+		result.Video = meta
+	} else {
+		fmt.Printf("Warning: failed to extract video metadata: %v\n", err)
+	}
+
 	if thumbFull, thumbRel, err := GenerateVideoThumbnail(fullPath, relativePath); err == nil {
 		result.ThumbnailFullPath = thumbFull
 		result.ThumbnailRelPath = thumbRel
+		applyPlaceholder(result)
 	} else {
 		fmt.Printf("Warning: failed to generate video thumbnail: %v\n", err)
 	}
+
+	if result.Video != nil {
+		if previewFull, previewRel, err := GenerateVideoHoverPreview(fullPath, relativePath, result.Video.DurationSec); err == nil {
+			result.AnimThumbFullPath = previewFull
+			result.AnimThumbRelPath = previewRel
+		} else {
+			fmt.Printf("Warning: failed to generate hover preview: %v\n", err)
+		}
+	}
+
+	// The web rendition is transcoded in the background; see queueWebVideo
+}
+
+// applyPlaceholder computes the BlurHash and dominant color from the thumbnail
+func applyPlaceholder(result *SavedFile) {
+	blurHash, color, err := GeneratePlaceholder(result.ThumbnailFullPath)
+	if err != nil {
+		fmt.Printf("Warning: failed to generate placeholder: %v\n", err)
+		return
+	}
+	result.BlurHash = blurHash
+	result.DominantColor = color
 }
 
 // CleanupFile removes a file from disk (used for rollback on DB errors).
@@ -201,6 +367,10 @@ func detectMediaType(mimeType string) string {
 		return "photo"
 	case strings.HasPrefix(mimeType, "video/"):
 		return "video"
+	case IsAudio(mimeType):
+		return "audio"
+	case IsDocument(mimeType):
+		return "document"
 	default:
 		return ""
 	}
@@ -213,21 +383,31 @@ func getMediaBasePath() string {
 	return "/mnt/storage/media"
 }
 
-func getThumbnailPath(relativePath string) (fullPath, relPath string) {
-	ext := filepath.Ext(relativePath)
-	basePath := strings.TrimSuffix(relativePath, ext) + ".jpg"
-	relPath = filepath.Join(".thumbs", basePath)
+// derivativePath returns where a file derived from an original is stored:
+// under dir, mirroring the original's path with suffix appended. The
+// original's extension is kept so files sharing a base name, like the two
+// halves of a RAW+JPEG pair or a Live Photo, never share derivatives.
+func derivativePath(dir, relativePath, suffix string) (fullPath, relPath string) {
+	relPath = filepath.Join(dir, relativePath+suffix)
 	fullPath = filepath.Join(getMediaBasePath(), relPath)
 	return fullPath, relPath
 }
 
+func getThumbnailPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".thumbs", relativePath, ".jpg")
+}
+
 // GenerateImageThumbnail creates a 300px thumbnail for an image file.
 func GenerateImageThumbnail(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
-	src, err := imaging.Open(srcPath, imaging.AutoOrientation(true))
+	src, err := openImageSRGB(srcPath)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to open image: %w", err)
 	}
+	return saveImageThumbnail(src, originalRelPath)
+}
 
+// saveImageThumbnail writes a 300px thumbnail of an already decoded image
+func saveImageThumbnail(src image.Image, originalRelPath string) (fullPath, relPath string, err error) {
 	thumb := imaging.Fit(src, ThumbnailSize, ThumbnailSize, imaging.Lanczos)
 	fullPath, relPath = getThumbnailPath(originalRelPath)
 
@@ -287,7 +467,7 @@ func extractVideoFrame(srcPath, tempFrame string) error {
 }
 
 func resizeFrameToThumbnail(tempFrame, destPath string) error {
-	img, err := imaging.Open(tempFrame)
+	img, err := openImage(tempFrame)
 	if err != nil {
 		return fmt.Errorf("failed to open extracted frame: %w", err)
 	}
@@ -309,11 +489,7 @@ func CleanupThumbnail(relativePath string) {
 }
 
 func getWebPath(relativePath string) (fullPath, relPath string) {
-	ext := filepath.Ext(relativePath)
-	basePath := strings.TrimSuffix(relativePath, ext) + ".webp"
-	relPath = filepath.Join(".web", basePath)
-	fullPath = filepath.Join(getMediaBasePath(), relPath)
-	return fullPath, relPath
+	return derivativePath(".web", relativePath, ".webp")
 }
 
 // GenerateWebOptimizedImage creates a 2400px max WebP version for fast web viewing.
@@ -325,10 +501,28 @@ func GenerateWebOptimizedImage(srcPath, originalRelPath string) (fullPath, relPa
 		return "", "", fmt.Errorf("failed to create web directory: %w", err)
 	}
 
-	// First resize the image to max 2400px dimension
-	src, err := imaging.Open(srcPath, imaging.AutoOrientation(true))
+	// Save a temporary PNG (max 2400px) for cwebp input
+	tempPNG := fullPath + ".temp.png"
+	defer os.Remove(tempPNG)
+
+	if err := saveWebSizedPNG(srcPath, tempPNG); err != nil {
+		return "", "", err
+	}
+
+	// Convert to WebP using cwebp
+	if err := convertToWebP(tempPNG, fullPath); err != nil {
+		return "", "", err
+	}
+
+	return fullPath, relPath, nil
+}
+
+// saveWebSizedPNG writes srcPath as a PNG, auto-oriented and scaled down to
+// at most WebOptimizedSize, for use as encoder input.
+func saveWebSizedPNG(srcPath, destPath string) error {
+	src, err := openImageSRGB(srcPath)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to open image: %w", err)
+		return fmt.Errorf("failed to open image: %w", err)
 	}
 
 	// Only resize if image is larger than WebOptimizedSize
@@ -338,22 +532,53 @@ func GenerateWebOptimizedImage(srcPath, originalRelPath string) (fullPath, relPa
 		src = imaging.Fit(src, WebOptimizedSize, WebOptimizedSize, imaging.Lanczos)
 	}
 
-	// Save as temporary PNG for cwebp input
+	if err := imaging.Save(src, destPath); err != nil {
+		return fmt.Errorf("failed to save temp image: %w", err)
+	}
+	return nil
+}
+
+func getAvifPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".avif", relativePath, ".avif")
+}
+
+// GenerateAVIFImage creates a 2400px max AVIF version for clients that support it.
+// Uses the avifenc CLI tool (libavif).
+func GenerateAVIFImage(srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	fullPath, relPath = getAvifPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create avif directory: %w", err)
+	}
+
 	tempPNG := fullPath + ".temp.png"
 	defer os.Remove(tempPNG)
 
-	if err := imaging.Save(src, tempPNG); err != nil {
-		return "", "", fmt.Errorf("failed to save temp image: %w", err)
+	if err := saveWebSizedPNG(srcPath, tempPNG); err != nil {
+		return "", "", err
 	}
 
-	// Convert to WebP using cwebp
-	if err := convertToWebP(tempPNG, fullPath); err != nil {
+	if err := convertToAVIF(tempPNG, fullPath); err != nil {
 		return "", "", err
 	}
 
 	return fullPath, relPath, nil
 }
 
+func convertToAVIF(srcPath, destPath string) error {
+	cmd := exec.Command("avifenc",
+		"-q", fmt.Sprintf("%d", AVIFQuality),
+		"-s", "6",
+		srcPath,
+		destPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("avifenc failed: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
 func convertToWebP(srcPath, destPath string) error {
 	cmd := exec.Command("cwebp",
 		"-q", fmt.Sprintf("%d", WebOptimizedQuality),
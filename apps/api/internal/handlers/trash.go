@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"storage-api/internal/models"
+	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// ListTrash handles GET /media/trash
+// Returns trashed items the caller may restore or purge: their own uploads,
+// or everything visible to them for admins. Most recently deleted first.
+// Only thumbnails are signed; nothing else of a trashed item is served.
+func (h *MediaHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser := h.getCurrentUser(r)
+	if currentUser == nil || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+
+	visibility := "mine"
+	if currentUser.IsAdmin() {
+		visibility = "all"
+	}
+
+	filter := repository.MediaListFilter{
+		HouseholdID: householdID,
+		UserID:      &currentUser.ID,
+		Visibility:  visibility,
+		Trashed:     true,
+		Page:        page,
+		PageSize:    pageSize,
+	}
+
+	items, totalCount, err := h.svc.List(r.Context(), filter)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list trash: %v", err),
+		})
+		return
+	}
+
+	if items == nil {
+		items = []models.MediaItem{}
+	}
+	for i := range items {
+		items[i].URLs = h.signer.TrashURLs(&items[i])
+	}
+
+	writeJSON(w, http.StatusOK, models.MediaListResponse{
+		Items:      items,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+	})
+}
+
+// Restore handles POST /media/{id}/restore
+// Like delete and purge, restricted to the uploader and admins.
+func (h *MediaHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMediaID(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := h.svc.GetTrashedByID(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to get media: %v", err),
+		})
+		return
+	}
+	currentUser := h.getCurrentUser(r)
+	if err != nil || !canView(currentUser, item) {
+		writeJSON(w, http.StatusNotFound, map[string]any{
+			"error": "media item not found in trash",
+		})
+		return
+	}
+
+	// Only whoever could delete the item may bring it back
+	if !canManage(currentUser, item.UploaderID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "only the uploader or an admin can restore this item",
+		})
+		return
+	}
+
+	if err := h.svc.Restore(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to restore media: %v", err),
+		})
+		return
+	}
+
+	item.DeletedAt.Valid = false
+	item.URLs = h.signer.URLs(item)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "restored successfully",
+		"item":    item,
+	})
+}
+
+// EmptyTrash handles DELETE /media/trash
+// Permanently deletes exactly what ListTrash shows the caller: their own
+// trashed uploads, or everything visible to them for admins. Other members'
+// private items are never purged by someone who could not see them.
+func (h *MediaHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser := h.getCurrentUser(r)
+	if currentUser == nil || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return
+	}
+
+	var uploaderID *uuid.UUID
+	if !currentUser.IsAdmin() {
+		uploaderID = &currentUser.ID
+	}
+
+	trashed, err := h.svc.ListTrashed(r.Context(), householdID, uploaderID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to list trash: %v", err),
+		})
+		return
+	}
+
+	items := make([]models.MediaItem, 0, len(trashed))
+	for _, item := range trashed {
+		if canView(currentUser, &item) {
+			items = append(items, item)
+		}
+	}
+
+	purged, err := h.purgeItems(r.Context(), items)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error":  fmt.Sprintf("failed to empty trash: %v", err),
+			"purged": purged,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "trash emptied",
+		"purged":  purged,
+	})
+}
+
+// PurgeExpiredTrash permanently deletes items that have been in the trash
+// longer than the retention period. Returns the number of items purged.
+func (h *MediaHandler) PurgeExpiredTrash(ctx context.Context, retention time.Duration) (int, error) {
+	items, err := h.svc.ListTrashedBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return h.purgeItems(ctx, items)
+}
+
+// purgeItems hard-deletes each item's row and then its files. Files are only
+// removed once the row is gone, so a concurrent restore keeps them intact.
+func (h *MediaHandler) purgeItems(ctx context.Context, items []models.MediaItem) (int, error) {
+	purged := 0
+	for i := range items {
+		item := &items[i]
+		ok, err := h.svc.Purge(ctx, item.ID)
+		if err != nil {
+			return purged, err
+		}
+		if !ok {
+			continue
+		}
+		deleteMediaFiles(item)
+		purged++
+	}
+	return purged, nil
+}
+
+// RunTrashPurge purges expired trash every interval until ctx is cancelled
+func (h *MediaHandler) RunTrashPurge(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := h.PurgeExpiredTrash(ctx, retention); err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Trash purge: permanently deleted %d items", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+)
+
+// TrimVideo writes the part of srcPath between startSec and endSec to
+// destPath. Streams are copied when possible, which is fast and lossless but
+// starts the clip at the keyframe before startSec; with reencode (or if
+// copying fails) the video is re-encoded as H.264/AAC for an exact cut.
+// Re-encoded output should use an .mp4 destPath.
+func TrimVideo(srcPath, destPath string, startSec, endSec float64, reencode bool) error {
+	if !reencode {
+		err := runTrim(srcPath, destPath, startSec, endSec,
+			"-c", "copy",
+			"-avoid_negative_ts", "make_zero",
+		)
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("Warning: stream copy trim failed, re-encoding: %v\n", err)
+	}
+
+	return runTrim(srcPath, destPath, startSec, endSec,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", "18",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "192k",
+	)
+}
+
+func runTrim(srcPath, destPath string, startSec, endSec float64, codecArgs ...string) error {
+	args := []string{
+		"-y",
+		"-ss", strconv.FormatFloat(startSec, 'f', 3, 64),
+		"-t", strconv.FormatFloat(endSec-startSec, 'f', 3, 64),
+		"-i", srcPath,
+		"-map", "0:v:0", "-map", "0:a?",
+	}
+	args = append(args, codecArgs...)
+	args = append(args, "-movflags", "+faststart", destPath)
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+	return nil
+}
+
+// ExtractFullFrame writes the full-resolution frame at atSec to destPath as a JPEG
+func ExtractFullFrame(srcPath, destPath string, atSec float64) error {
+	cmd := exec.Command("ffmpeg",
+		"-y", "-ss", strconv.FormatFloat(atSec, 'f', 3, 64), "-i", srcPath,
+		"-frames:v", "1", "-q:v", "1", destPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+	if !fileExists(destPath) {
+		return fmt.Errorf("no frame at %.3fs", atSec)
+	}
+	return nil
+}
+
+type trimRequest struct {
+	StartSec *float64 `json:"startSec"`
+	EndSec   *float64 `json:"endSec"`
+	Reencode bool     `json:"reencode"` // Exact cut instead of a keyframe-aligned stream copy
+}
+
+type frameRequest struct {
+	TimeSec *float64 `json:"timeSec"`
+}
+
+// Trim handles POST /media/{id}/trim
+// Saves the part of a video between startSec and endSec as a new video item.
+func (h *MediaHandler) Trim(w http.ResponseWriter, r *http.Request) {
+	source := h.getEditableVideo(w, r)
+	if source == nil {
+		return
+	}
+
+	var req trimRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.StartSec == nil || req.EndSec == nil || *req.StartSec < 0 || *req.EndSec <= *req.StartSec ||
+		(source.DurationSec > 0 && *req.StartSec >= float64(source.DurationSec)) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "startSec and endSec must be within the video and startSec must be before endSec",
+		})
+		return
+	}
+
+	ext, mimeType := filepath.Ext(source.Path), source.MimeType
+	if req.Reencode {
+		ext, mimeType = ".mp4", "video/mp4"
+	}
+	filename := fmt.Sprintf("%s_trim_%s-%s%s", derivedBaseName(source),
+		formatSeconds(*req.StartSec), formatSeconds(*req.EndSec), ext)
+
+	h.createDerivedItem(w, r, source, "video", filename, mimeType, *req.StartSec,
+		func(srcPath, destPath string) error {
+			return TrimVideo(srcPath, destPath, *req.StartSec, *req.EndSec, req.Reencode)
+		})
+}
+
+// Frame handles POST /media/{id}/frame
+// Saves the full-resolution frame at timeSec as a new photo item.
+func (h *MediaHandler) Frame(w http.ResponseWriter, r *http.Request) {
+	source := h.getEditableVideo(w, r)
+	if source == nil {
+		return
+	}
+
+	var req frameRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.TimeSec == nil || *req.TimeSec < 0 || (source.DurationSec > 0 && *req.TimeSec > float64(source.DurationSec)) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "timeSec must be between 0 and the video's duration",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("%s_frame_%s.jpg", derivedBaseName(source), formatSeconds(*req.TimeSec))
+
+	h.createDerivedItem(w, r, source, "photo", filename, "image/jpeg", *req.TimeSec,
+		func(srcPath, destPath string) error {
+			return ExtractFullFrame(srcPath, destPath, *req.TimeSec)
+		})
+}
+
+// getEditableVideo returns the video item in the URL if the current user may
+// view and modify it. Otherwise writes an error response and returns nil.
+func (h *MediaHandler) getEditableVideo(w http.ResponseWriter, r *http.Request) *models.MediaItem {
+	currentUser, item := h.getViewableItem(w, r)
+	if item == nil {
+		return nil
+	}
+
+	if !canManage(currentUser, item.UploaderID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "not allowed to modify this item",
+		})
+		return nil
+	}
+
+	if item.Type != "video" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "only videos can be trimmed or have frames exported",
+		})
+		return nil
+	}
+	return item
+}
+
+// createDerivedItem runs generate to write a file derived from source, stores
+// it like an upload and creates an item for it. The new item inherits the
+// source's household, uploader, privacy, location and camera, and its capture
+// date is the source's offset by offsetSec.
+func (h *MediaHandler) createDerivedItem(w http.ResponseWriter, r *http.Request, source *models.MediaItem, mediaType, filename, mimeType string, offsetSec float64, generate func(srcPath, destPath string) error) {
+	// Refuse up front rather than overwrite another item's file
+	relPath, _ := generateStoragePath(mediaType, filename)
+	if existing, err := h.svc.GetByPath(r.Context(), source.HouseholdID, relPath); err == nil {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":    "file already exists",
+			"existing": existing,
+		})
+		return
+	}
+
+	tempDir, err := os.MkdirTemp("", "derived-")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to create temp directory: %v", err),
+		})
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	tempPath := filepath.Join(tempDir, sanitizeFilename(filename))
+	srcPath := filepath.Join(getMediaBasePath(), source.Path)
+	if err := generate(srcPath, tempPath); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	item, saved, err := h.saveDerivedFile(r.Context(), source, tempPath, mediaType, filename, mimeType, offsetSec)
+	if err != nil {
+		if saved != nil {
+			CleanupFiles(saved.FullPath, saved.PreviewFullPath, saved.ThumbnailFullPath, saved.WebFullPath, saved.AvifFullPath, saved.AnimThumbFullPath)
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if item.WebVideoStatus == models.WebVideoStatusPending {
+		h.wakeWebVideo()
+	}
+	if item.HLSStatus == models.HLSStatusPending {
+		h.wakeHLS()
+	}
+
+	item.URLs = h.signer.URLs(item)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"message": "item created",
+		"item":    item,
+	})
+}
+
+// saveDerivedFile moves a generated file into storage and creates its item.
+// On a database error the saved files are returned so they can be removed.
+func (h *MediaHandler) saveDerivedFile(ctx context.Context, source *models.MediaItem, tempPath, mediaType, filename, mimeType string, offsetSec float64) (*models.MediaItem, *SavedFile, error) {
+	f, err := os.Open(tempPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open generated file: %w", err)
+	}
+	defer f.Close()
+
+	saved, err := SaveUploadedFile(f, mediaType, filename, mimeType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item := &models.MediaItem{
+		HouseholdID:      source.HouseholdID,
+		UploaderID:       source.UploaderID,
+		IsPrivate:        source.IsPrivate,
+		Path:             saved.RelativePath,
+		Type:             mediaType,
+		MimeType:         mimeType,
+		SizeBytes:        saved.Size,
+		SHA256:           saved.SHA256,
+		PreviewPath:      saved.PreviewRelativePath,
+		ThumbnailPath:    saved.ThumbnailRelPath,
+		WebPath:          saved.WebRelPath,
+		AvifPath:         saved.AvifRelPath,
+		BlurHash:         saved.BlurHash,
+		DominantColor:    saved.DominantColor,
+		OriginalFilename: filename,
+	}
+	if saved.Metadata != nil {
+		populateItemMetadata(item, saved.Metadata)
+	}
+	if saved.Video != nil {
+		populateItemVideo(item, saved.Video)
+	}
+	item.AnimatedThumbnailPath = saved.AnimThumbRelPath
+
+	// Copied container metadata still has the source's start time
+	item.TakenAt = nil
+	if source.TakenAt != nil {
+		takenAt := source.TakenAt.Add(time.Duration(offsetSec * float64(time.Second)))
+		item.TakenAt = &takenAt
+	}
+	if item.Latitude == nil && item.Longitude == nil {
+		item.Latitude, item.Longitude = source.Latitude, source.Longitude
+	}
+	if item.CameraMake == "" && item.CameraModel == "" {
+		item.CameraMake, item.CameraModel = source.CameraMake, source.CameraModel
+	}
+	queueWebVideo(item)
+	h.queueHLS(item)
+
+	if err := h.svc.Create(ctx, item); err != nil {
+		return nil, saved, fmt.Errorf("failed to save to database: %w", err)
+	}
+	return item, saved, nil
+}
+
+// derivedBaseName is the source's original file name without its extension
+func derivedBaseName(item *models.MediaItem) string {
+	name := item.OriginalFilename
+	if name == "" {
+		name = filepath.Base(item.Path)
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// formatSeconds formats a timestamp to the millisecond for a file name, e.g. "12.5"
+func formatSeconds(sec float64) string {
+	return strconv.FormatFloat(math.Round(sec*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VideoMetadata contains metadata extracted from a video container by ffprobe
+type VideoMetadata struct {
+	DurationSec float64
+	Width       int // Display width (after rotation)
+	Height      int // Display height (after rotation)
+	Rotation    int // Clockwise degrees: 0, 90, 180 or 270
+	VideoCodec  string
+	HasAudio    bool
+	Bitrate     int64 // Bits per second, whole file
+	FrameRate   float64
+	TakenAt     *time.Time
+	Latitude    *float64
+	Longitude   *float64
+	CameraMake  string
+	CameraModel string
+
+	// Apple content identifier, shared by a Live Photo's still and video
+	ContentIdentifier string
+}
+
+// ffprobeOutput is the subset of `ffprobe -print_format json` output we use
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string            `json:"codec_type"`
+		CodecName    string            `json:"codec_name"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		AvgFrameRate string            `json:"avg_frame_rate"`
+		Tags         map[string]string `json:"tags"`
+		SideDataList []struct {
+			Rotation *float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// ExtractVideoMetadata reads duration, dimensions, codec and QuickTime/MP4
+// metadata atoms from a video using ffprobe.
+func ExtractVideoMetadata(filePath string) (*VideoMetadata, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		filePath,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	meta := &VideoMetadata{}
+	meta.DurationSec, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	meta.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	for _, s := range probe.Streams {
+		if s.CodecType == "audio" {
+			meta.HasAudio = true
+		}
+	}
+
+	// Use the first video stream
+	for _, s := range probe.Streams {
+		if s.CodecType != "video" {
+			continue
+		}
+		meta.VideoCodec = s.CodecName
+		meta.FrameRate = parseFrameRate(s.AvgFrameRate)
+
+		// Rotation is a display matrix (newer ffmpeg) or a "rotate" tag (older)
+		rotation := 0.0
+		if r, err := strconv.ParseFloat(s.Tags["rotate"], 64); err == nil {
+			rotation = r
+		}
+		for _, sd := range s.SideDataList {
+			if sd.Rotation != nil {
+				// Display matrix rotation is counter-clockwise
+				rotation = -*sd.Rotation
+			}
+		}
+		meta.Rotation = ((int(math.Round(rotation)) % 360) + 360) % 360
+
+		meta.Width, meta.Height = s.Width, s.Height
+		if meta.Rotation == 90 || meta.Rotation == 270 {
+			meta.Width, meta.Height = s.Height, s.Width
+		}
+		break
+	}
+
+	tags := lowerKeys(probe.Format.Tags)
+
+	// Apple's creationdate keeps the local offset; creation_time is UTC
+	for _, key := range []string{"com.apple.quicktime.creationdate", "creation_time"} {
+		if t, ok := parseVideoTime(tags[key]); ok {
+			meta.TakenAt = &t
+			break
+		}
+	}
+
+	for _, key := range []string{"com.apple.quicktime.location.iso6709", "location"} {
+		if lat, lon, ok := parseISO6709(tags[key]); ok {
+			meta.Latitude = &lat
+			meta.Longitude = &lon
+			break
+		}
+	}
+
+	meta.CameraMake = tags["com.apple.quicktime.make"]
+	meta.CameraModel = tags["com.apple.quicktime.model"]
+	meta.ContentIdentifier = tags["com.apple.quicktime.content.identifier"]
+
+	return meta, nil
+}
+
+func lowerKeys(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// parseFrameRate parses ffprobe's "num/den" frame rate
+func parseFrameRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*1000) / 1000
+}
+
+// videoTimeLayouts are the timestamp formats found in MP4/QuickTime tags
+var videoTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+}
+
+func parseVideoTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range videoTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			// Cameras without a clock write the MP4 epoch (1904) or zero
+			if t.Year() < 1971 {
+				return time.Time{}, false
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// iso6709Pattern matches "+DD.DDDD-DDD.DDDD" style coordinates with an
+// optional altitude and trailing slash, e.g. "+37.3349-122.0090+021.000/"
+var iso6709Pattern = regexp.MustCompile(`^([+-][0-9.]+)([+-][0-9.]+)([+-][0-9.]+)?(CRS[^/]*)?/?$`)
+
+// parseISO6709 parses an ISO 6709 location string. Degrees, degrees+minutes
+// (DDMM.MM) and degrees+minutes+seconds (DDMMSS.SS) forms are supported.
+func parseISO6709(s string) (lat, lon float64, ok bool) {
+	m := iso6709Pattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+
+	lat, ok1 := parseISO6709Component(m[1], 2)
+	lon, ok2 := parseISO6709Component(m[2], 3)
+	if !ok1 || !ok2 || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parseISO6709Component parses a signed coordinate whose whole degrees use
+// degDigits digits; extra integer digits are minutes and seconds.
+func parseISO6709Component(s string, degDigits int) (float64, bool) {
+	sign := 1.0
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = s[1:]
+
+	intPart, _, _ := strings.Cut(s, ".")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch extra := len(intPart) - degDigits; {
+	case extra <= 0: // Degrees
+	case extra == 2: // Degrees and minutes
+		deg := math.Floor(v / 100)
+		v = deg + (v-deg*100)/60
+	case extra == 4: // Degrees, minutes and seconds
+		deg := math.Floor(v / 10000)
+		minutes := math.Floor((v - deg*10000) / 100)
+		seconds := v - deg*10000 - minutes*100
+		v = deg + minutes/60 + seconds/3600
+	default:
+		return 0, false
+	}
+	return sign * v, true
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// HoverPreviewSeconds is the length of the silent preview played when
+// hovering a video in the grid
+const HoverPreviewSeconds = 3
+
+func getHoverPreviewPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".thumbs", relativePath, ".anim.mp4")
+}
+
+// GenerateVideoPoster replaces a video's thumbnail with the frame at atSec.
+// The new thumbnail is only moved into place once it has been written.
+func GenerateVideoPoster(srcPath, originalRelPath string, atSec float64) (fullPath, relPath string, err error) {
+	fullPath, relPath = getThumbnailPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	tempFrame := fullPath + ".temp.jpg"
+	defer os.Remove(tempFrame)
+	tempThumb := fullPath + ".temp.thumb.jpg"
+	defer os.Remove(tempThumb)
+
+	cmd := exec.Command("ffmpeg",
+		"-y", "-ss", strconv.FormatFloat(atSec, 'f', 3, 64), "-i", srcPath,
+		"-frames:v", "1", "-q:v", "2", tempFrame,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+	if !fileExists(tempFrame) {
+		return "", "", fmt.Errorf("no frame at %.3fs", atSec)
+	}
+
+	if err := resizeFrameToThumbnail(tempFrame, tempThumb); err != nil {
+		return "", "", err
+	}
+	if err := os.Rename(tempThumb, fullPath); err != nil {
+		return "", "", fmt.Errorf("failed to save video thumbnail: %w", err)
+	}
+
+	return fullPath, relPath, nil
+}
+
+// GenerateVideoHoverPreview creates a short, silent, thumbnail-sized MP4 from
+// a video for hover playback in the grid. The clip starts a tenth of the way
+// in, which skips most fade-ins and fumbling with the camera.
+func GenerateVideoHoverPreview(srcPath, originalRelPath string, durationSec float64) (fullPath, relPath string, err error) {
+	fullPath, relPath = getHoverPreviewPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create thumbnail directory: %w", err)
+	}
+
+	start := max(0, min(durationSec*0.1, durationSec-HoverPreviewSeconds))
+
+	tempPath := fullPath + ".temp.mp4"
+	defer os.Remove(tempPath)
+
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-t", strconv.Itoa(HoverPreviewSeconds),
+		"-i", srcPath,
+		"-an",
+		"-vf", fmt.Sprintf(
+			"scale=%[1]d:%[1]d:force_original_aspect_ratio=decrease,scale=trunc(iw/2)*2:trunc(ih/2)*2",
+			ThumbnailSize,
+		),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "28",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-map_metadata", "-1",
+		tempPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		return "", "", fmt.Errorf("failed to save hover preview: %w", err)
+	}
+
+	return fullPath, relPath, nil
+}
+
+// applyPosterFrame replaces a processed video's default thumbnail with the
+// frame at atSec, keeping the default if extraction fails
+func applyPosterFrame(result *SavedFile, fullPath, relativePath string, atSec float64) {
+	thumbFull, thumbRel, err := GenerateVideoPoster(fullPath, relativePath, atSec)
+	if err != nil {
+		fmt.Printf("Warning: failed to extract poster frame: %v\n", err)
+		return
+	}
+	result.ThumbnailFullPath = thumbFull
+	result.ThumbnailRelPath = thumbRel
+	applyPlaceholder(result)
+}
+
+type posterRequest struct {
+	TimeSec *float64 `json:"timeSec"`
+}
+
+// Poster handles PUT /media/{id}/poster
+// Regenerates a video's thumbnail (and placeholder) from the frame at timeSec.
+func (h *MediaHandler) Poster(w http.ResponseWriter, r *http.Request) {
+	currentUser, item := h.getViewableItem(w, r)
+	if item == nil {
+		return
+	}
+
+	if !canManage(currentUser, item.UploaderID) {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error": "not allowed to modify this item",
+		})
+		return
+	}
+
+	if item.Type != "video" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "poster frames are only supported for videos",
+		})
+		return
+	}
+
+	var req posterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.TimeSec == nil || *req.TimeSec < 0 || (item.DurationSec > 0 && *req.TimeSec > float64(item.DurationSec)) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": "timeSec must be between 0 and the video's duration",
+		})
+		return
+	}
+
+	srcPath := filepath.Join(getMediaBasePath(), item.Path)
+	thumbFull, thumbRel, err := GenerateVideoPoster(srcPath, item.Path, *req.TimeSec)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to extract frame: %v", err),
+		})
+		return
+	}
+
+	blurHash, color, err := GeneratePlaceholder(thumbFull)
+	if err != nil {
+		fmt.Printf("Warning: failed to generate placeholder: %v\n", err)
+	}
+
+	if err := h.svc.SetPoster(r.Context(), item.ID, thumbRel, blurHash, color, *req.TimeSec); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to save poster: %v", err),
+		})
+		return
+	}
+
+	// Re-read for the new UpdatedAt, which busts cached thumbnail URLs
+	updated, err := h.svc.GetByID(r.Context(), item.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to reload item: %v", err),
+		})
+		return
+	}
+
+	updated.URLs = h.signer.URLs(updated)
+	writeJSON(w, http.StatusOK, map[string]any{"item": updated})
+}
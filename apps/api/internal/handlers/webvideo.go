@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+)
+
+// WebVideoHeight is the maximum height (short side for portrait videos) of
+// web-optimized MP4s
+const WebVideoHeight = 1080
+
+// WebVideoCRF is the x264 constant rate factor for web-optimized MP4s
+const WebVideoCRF = 23
+
+func getWebVideoPath(relativePath string) (fullPath, relPath string) {
+	return derivativePath(".web", relativePath, ".mp4")
+}
+
+// GenerateWebVideo transcodes a video to an H.264/AAC MP4 of at most 1080p
+// with the moov atom up front (faststart), so it plays in every browser and
+// starts streaming before it has fully downloaded. This takes minutes on
+// small hardware, so uploads queue it for RunWebVideoTranscoder.
+func GenerateWebVideo(ctx context.Context, srcPath, originalRelPath string) (fullPath, relPath string, err error) {
+	fullPath, relPath = getWebVideoPath(originalRelPath)
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create web directory: %w", err)
+	}
+
+	// Write to a temporary name so a failed or interrupted transcode never
+	// leaves a truncated file behind to be served
+	tempPath := fullPath + ".temp.mp4"
+	defer os.Remove(tempPath)
+
+	// ffmpeg applies the rotation before filtering, so iw/ih are display
+	// dimensions. Limit the short side and keep dimensions even for yuv420p.
+	scale := fmt.Sprintf(
+		"scale='if(gte(iw,ih),-2,min(%[1]d,iw))':'if(gte(iw,ih),min(%[1]d,ih),-2)'",
+		WebVideoHeight,
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y", "-i", srcPath,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", scale,
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-crf", fmt.Sprintf("%d", WebVideoCRF),
+		"-profile:v", "high",
+		"-pix_fmt", "yuv420p",
+		"-c:a", "aac",
+		"-b:a", "128k",
+		"-ac", "2",
+		"-movflags", "+faststart",
+		"-map_metadata", "-1",
+		tempPath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ffmpeg failed: %v, output: %s", err, lastLines(string(output), 5))
+	}
+
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		return "", "", fmt.Errorf("failed to save web video: %w", err)
+	}
+
+	return fullPath, relPath, nil
+}
+
+// queueWebVideo marks a new video for a web rendition. Call before the item
+// is created; wakeWebVideo starts the work after. Until the rendition is
+// ready, downloads serve the original.
+func queueWebVideo(item *models.MediaItem) {
+	if item.Type == "video" && item.WebPath == "" {
+		item.WebVideoStatus = models.WebVideoStatusPending
+	}
+}
+
+// wakeWebVideo nudges the transcoder to check the queue without waiting for
+// its next poll
+func (h *MediaHandler) wakeWebVideo() {
+	select {
+	case h.webVideoWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunWebVideoTranscoder generates web renditions for queued videos, one at a
+// time, until ctx is cancelled. Like HLS streams, the queue lives in the
+// database so work interrupted by a restart is picked up again.
+func (h *MediaHandler) RunWebVideoTranscoder(ctx context.Context, interval time.Duration) {
+	if n, err := h.svc.ResetWebVideoProcessing(ctx); err != nil {
+		log.Printf("Web video: failed to requeue interrupted renditions: %v", err)
+	} else if n > 0 {
+		log.Printf("Web video: requeued %d interrupted renditions", n)
+	}
+
+	runTranscodeQueue(ctx, interval, h.webVideoWake, "Web video", h.transcodeNextWebVideo)
+}
+
+// transcodeNextWebVideo generates the rendition for the next queued video.
+// Returns false if the queue is empty.
+func (h *MediaHandler) transcodeNextWebVideo(ctx context.Context) (bool, error) {
+	item, err := h.svc.NextWebVideoCandidate(ctx)
+	if errors.Is(err, models.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to find queued videos: %w", err)
+	}
+
+	if err := h.svc.SetWebVideoStatus(ctx, item.ID, models.WebVideoStatusProcessing); err != nil {
+		return false, fmt.Errorf("failed to claim %s: %w", item.ID, err)
+	}
+	log.Printf("Web video: transcoding %s", item.Path)
+
+	srcPath := filepath.Join(getMediaBasePath(), item.Path)
+	fullPath, relPath, err := GenerateWebVideo(ctx, srcPath, item.Path)
+	if ctx.Err() != nil {
+		// Shutting down; the item is requeued on the next start
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Web video: failed for %s: %v", item.Path, err)
+		if err := h.svc.SetWebVideoStatus(ctx, item.ID, models.WebVideoStatusFailed); err != nil {
+			return false, fmt.Errorf("failed to update %s: %w", item.ID, err)
+		}
+		return true, nil
+	}
+
+	if err := h.svc.SetWebVideoReady(ctx, item.ID, relPath); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			// Deleted while transcoding
+			os.Remove(fullPath)
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to update %s: %w", item.ID, err)
+	}
+	log.Printf("Web video: ready for %s", item.Path)
+	return true, nil
+}
+
+// lastLines returns the last n lines of s; ffmpeg prints a long banner
+// before the actual error
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"storage-api/internal/models"
+	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// MaxZipItems limits how many items a single ZIP download can contain
+const MaxZipItems = 5000
+
+// ZipManifestName is the name of the metadata manifest inside ZIP downloads
+const ZipManifestName = "manifest.json"
+
+// ZIP download variants
+const (
+	ZipVariantOriginal = "original" // Original uploaded files
+	ZipVariantWeb      = "web"      // Web derivatives (WebP/JPEG preview), original if none
+)
+
+// zipRequest selects the items of a ZIP download: explicit IDs, or a
+// taken-date range when no IDs are given.
+type zipRequest struct {
+	IDs     []uuid.UUID `json:"ids,omitempty"`
+	From    *time.Time  `json:"from,omitempty"` // Inclusive
+	To      *time.Time  `json:"to,omitempty"`   // Exclusive
+	Variant string      `json:"variant,omitempty"`
+}
+
+// zipEntry is one media file in a ZIP download
+type zipEntry struct {
+	Name     string            `json:"file"`
+	Item     *models.MediaItem `json:"item"`
+	fullPath string
+	size     int64
+}
+
+// zipManifest is written as manifest.json at the root of every ZIP download
+type zipManifest struct {
+	Variant string      `json:"variant"`
+	Items   []*zipEntry `json:"items"`
+	Missing []uuid.UUID `json:"missing,omitempty"` // Selected items whose file is not on disk
+}
+
+// Zip handles GET and POST /media/zip
+// Streams a ZIP of the selected items without buffering to disk. Files are
+// stored uncompressed (media is already compressed) in a deterministic order,
+// so an interrupted download can be resumed with a "Range: bytes=N-" request.
+// ZIP64 is used automatically for archives over 4GB.
+func (h *MediaHandler) Zip(w http.ResponseWriter, r *http.Request) {
+	householdID, ok := parseHouseholdID(w, r)
+	if !ok {
+		return
+	}
+
+	currentUser := h.getCurrentUser(r)
+	if currentUser == nil || currentUser.HouseholdID != householdID {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error": "user not authorized for this household",
+		})
+		return
+	}
+
+	req, err := parseZipRequest(w, r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	items, err := h.selectZipItems(r.Context(), currentUser, householdID, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrInvalidInput) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	manifest := buildZipManifest(items, req.Variant)
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to build manifest: %v", err),
+		})
+		return
+	}
+
+	etag := zipETag(manifestJSON, manifest.Items)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"media-%s.zip\"", etag[1:13]))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+
+	// Resume: regenerate the identical archive and skip what the client has
+	offset, ranged := parseResumeOffset(r, etag)
+	if !ranged {
+		w.WriteHeader(http.StatusOK)
+		logZipError(writeZip(r.Context(), w, manifestJSON, manifest.Items, openZipEntry))
+		return
+	}
+
+	size, err := zipSize(r.Context(), manifestJSON, manifest.Items)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": fmt.Sprintf("failed to size archive: %v", err),
+		})
+		return
+	}
+	if offset >= size {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+	w.Header().Set("Content-Length", strconv.FormatInt(size-offset, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	logZipError(writeZip(r.Context(), &skipWriter{w: w, skip: offset}, manifestJSON, manifest.Items, openZipEntry))
+}
+
+// logZipError records why an archive was cut short once its headers were
+// sent. A cancelled request is just the client going away.
+func logZipError(err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("ZIP: archive truncated: %v", err)
+	}
+}
+
+// parseZipRequest reads the selection from a JSON body (POST) or query
+// parameters (GET: ids=a,b,c&from=&to=&variant=)
+func parseZipRequest(w http.ResponseWriter, r *http.Request) (*zipRequest, error) {
+	req := &zipRequest{}
+
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, MaxJSONBodySize)
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %v", err)
+		}
+	} else {
+		q := r.URL.Query()
+		for _, part := range strings.Split(q.Get("ids"), ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid id %q", part)
+			}
+			req.IDs = append(req.IDs, id)
+		}
+		var err error
+		if req.From, err = parseDateParam(q.Get("from")); err != nil {
+			return nil, fmt.Errorf("invalid from parameter: %v", err)
+		}
+		if req.To, err = parseDateParam(q.Get("to")); err != nil {
+			return nil, fmt.Errorf("invalid to parameter: %v", err)
+		}
+		req.Variant = q.Get("variant")
+	}
+
+	if req.Variant == "" {
+		req.Variant = ZipVariantOriginal
+	}
+	if req.Variant != ZipVariantOriginal && req.Variant != ZipVariantWeb {
+		return nil, fmt.Errorf("variant must be %q or %q", ZipVariantOriginal, ZipVariantWeb)
+	}
+	if len(req.IDs) == 0 && req.From == nil && req.To == nil {
+		return nil, errors.New("select items with ids or a from/to date range")
+	}
+	if len(req.IDs) > MaxZipItems {
+		return nil, fmt.Errorf("at most %d items can be downloaded at once", MaxZipItems)
+	}
+	return req, nil
+}
+
+// parseDateParam parses an RFC 3339 timestamp or a YYYY-MM-DD date (UTC)
+func parseDateParam(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// selectZipItems loads the requested items the user can see, in a stable order.
+// Downloads of originals also include the items' linked files.
+func (h *MediaHandler) selectZipItems(ctx context.Context, u *models.User, householdID uuid.UUID, req *zipRequest) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+
+	if len(req.IDs) > 0 {
+		found, err := h.svc.GetByIDs(ctx, uniqueIDs(req.IDs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get media: %w", err)
+		}
+		for _, item := range found {
+			if canView(u, &item) {
+				items = append(items, item)
+			}
+		}
+	} else {
+		filter := repository.MediaListFilter{
+			HouseholdID: householdID,
+			UserID:      &u.ID,
+			Visibility:  "all",
+			Archived:    "all",
+			TakenAfter:  req.From,
+			TakenBefore: req.To,
+			PageSize:    100,
+		}
+		for page := 1; ; page++ {
+			filter.Page = page
+			batch, total, err := h.svc.List(ctx, filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list media: %w", err)
+			}
+			if total > MaxZipItems {
+				return nil, fmt.Errorf("%w: range contains %d items, at most %d can be downloaded at once",
+					models.ErrInvalidInput, total, MaxZipItems)
+			}
+			items = append(items, batch...)
+			if len(batch) == 0 || int64(len(items)) >= total {
+				break
+			}
+		}
+	}
+
+	if req.Variant == ZipVariantOriginal {
+		children, err := h.linkedOriginals(ctx, u, items)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, children...)
+		if len(items) > MaxZipItems {
+			return nil, fmt.Errorf("%w: selection contains %d files, at most %d can be downloaded at once",
+				models.ErrInvalidInput, len(items), MaxZipItems)
+		}
+	}
+
+	// Oldest first, ties broken by ID, so regenerated archives are byte-identical
+	sort.Slice(items, func(i, j int) bool {
+		ti, tj := zipItemTime(&items[i]), zipItemTime(&items[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return items[i].ID.String() < items[j].ID.String()
+	})
+	return items, nil
+}
+
+// linkedOriginals returns the files that listings show through another item
+// and that aren't selected already: Live Photo motion videos and RAW files
+// stacked under a JPEG. Without them an export of originals would be lossy.
+func (h *MediaHandler) linkedOriginals(ctx context.Context, u *models.User, items []models.MediaItem) ([]models.MediaItem, error) {
+	selected := make(map[uuid.UUID]bool, len(items))
+	for i := range items {
+		selected[items[i].ID] = true
+	}
+
+	var ids []uuid.UUID
+	for i := range items {
+		for _, id := range []*uuid.UUID{items[i].LivePhotoVideoID, items[i].StackedRawID} {
+			if id != nil && !selected[*id] {
+				selected[*id] = true
+				ids = append(ids, *id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	found, err := h.svc.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked media: %w", err)
+	}
+	var children []models.MediaItem
+	for _, item := range found {
+		if canView(u, &item) {
+			children = append(children, item)
+		}
+	}
+	return children, nil
+}
+
+// zipItemTime is the date an item is ordered and timestamped by in the archive
+func zipItemTime(item *models.MediaItem) time.Time {
+	if item.TakenAt != nil {
+		return item.TakenAt.UTC()
+	}
+	return item.CreatedAt.UTC()
+}
+
+// buildZipManifest resolves each item's file for the variant and assigns
+// collision-free archive names based on OriginalFilename.
+func buildZipManifest(items []models.MediaItem, variant string) *zipManifest {
+	manifest := &zipManifest{Variant: variant, Items: []*zipEntry{}}
+	used := map[string]bool{strings.ToLower(ZipManifestName): true}
+
+	for i := range items {
+		item := &items[i]
+
+		fullPath := filepath.Join(getMediaBasePath(), item.Path)
+		if variant == ZipVariantWeb {
+			fullPath, _ = resolveDownloadPath(item)
+		}
+
+		info, err := os.Stat(fullPath)
+		if err != nil || !info.Mode().IsRegular() {
+			manifest.Missing = append(manifest.Missing, item.ID)
+			continue
+		}
+
+		name := item.OriginalFilename
+		if name == "" {
+			name = filepath.Base(item.Path)
+		}
+		name = sanitizeFilename(name)
+		if ext := filepath.Ext(fullPath); variant == ZipVariantWeb && !strings.EqualFold(ext, filepath.Ext(name)) {
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + ext
+		}
+
+		manifest.Items = append(manifest.Items, &zipEntry{
+			Name:     uniqueZipName(name, used),
+			Item:     manifestItem(item),
+			fullPath: fullPath,
+			size:     info.Size(),
+		})
+	}
+	return manifest
+}
+
+// manifestItem copies an item without the per-request fields, so the manifest
+// (and therefore the archive) only changes when the item itself does.
+func manifestItem(item *models.MediaItem) *models.MediaItem {
+	c := *item
+	c.IsFavorite = false
+	c.Rating = 0
+	c.CommentCount = 0
+	c.URLs = nil
+	return &c
+}
+
+// uniqueZipName returns name, or "name (n).ext" if it is already taken
+// (case-insensitively, for Windows and macOS extractors), and marks it used.
+func uniqueZipName(name string, used map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for n := 1; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// zipETag identifies the exact archive contents for resume validation
+func zipETag(manifestJSON []byte, entries []*zipEntry) string {
+	hasher := sha256.New()
+	hasher.Write(manifestJSON)
+	for _, e := range entries {
+		fmt.Fprintf(hasher, "%s:%d\n", e.fullPath, e.size)
+	}
+	return `"` + hex.EncodeToString(hasher.Sum(nil))[:32] + `"`
+}
+
+// parseResumeOffset returns the start offset of a "Range: bytes=N-" request.
+// Other range forms, and ranges whose If-Range does not match, get the full archive.
+func parseResumeOffset(r *http.Request, etag string) (int64, bool) {
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		return 0, false
+	}
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != etag {
+		return 0, false
+	}
+
+	spec, ok := strings.CutPrefix(rangeHeader, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, false
+	}
+	start, end, _ := strings.Cut(spec, "-")
+	if end != "" {
+		return 0, false
+	}
+	offset, err := strconv.ParseInt(start, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
+
+// openZipEntry opens the file backing an entry
+func openZipEntry(e *zipEntry) (io.ReadCloser, error) {
+	return os.Open(e.fullPath)
+}
+
+// zipSize computes the exact archive length by generating it with zero-filled
+// content into a counter. Entry lengths don't depend on file content.
+func zipSize(ctx context.Context, manifestJSON []byte, entries []*zipEntry) (int64, error) {
+	counter := &countingWriter{}
+	err := writeZip(ctx, counter, manifestJSON, entries, func(e *zipEntry) (io.ReadCloser, error) {
+		return io.NopCloser(io.LimitReader(zeroReader{}, e.size)), nil
+	})
+	return counter.n, err
+}
+
+// writeZip writes the manifest followed by every entry. It stops early if ctx
+// is cancelled (e.g. the client disconnected).
+func writeZip(ctx context.Context, w io.Writer, manifestJSON []byte, entries []*zipEntry, open func(*zipEntry) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   ZipManifestName,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(manifestJSON); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     e.Name,
+			Method:   zip.Store,
+			Modified: zipItemTime(e.Item),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write %s (%s): %w", e.Item.ID, e.Name, err)
+		}
+
+		src, err := open(e)
+		if err != nil {
+			return fmt.Errorf("failed to open %s (%s): %w", e.Item.ID, e.Name, err)
+		}
+		// Copy exactly the size the archive was planned with
+		_, err = io.CopyN(fw, &ctxReader{ctx: ctx, r: src}, e.size)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write %s (%s): %w", e.Item.ID, e.Name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// ctxReader fails reads once its context is cancelled
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// skipWriter discards the first skip bytes written to it
+type skipWriter struct {
+	w    io.Writer
+	skip int64
+}
+
+func (s *skipWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if s.skip >= int64(n) {
+		s.skip -= int64(n)
+		return n, nil
+	}
+	p = p[s.skip:]
+	s.skip = 0
+	if _, err := s.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// countingWriter counts and discards written bytes
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// zeroReader yields an endless stream of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SmartAlbum is a saved, dynamically evaluated collection of media.
+// Its contents are never stored; they are computed from Rules on every read.
+type SmartAlbum struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	HouseholdID uuid.UUID       `gorm:"type:uuid;not null;index" json:"householdId"`
+	OwnerID     *uuid.UUID      `gorm:"type:uuid" json:"ownerId,omitempty"`
+	Name        string          `gorm:"size:255;not null" json:"name"`
+	Rules       SmartAlbumRules `gorm:"type:jsonb;serializer:json;not null" json:"rules"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for GORM
+func (SmartAlbum) TableName() string {
+	return "smart_albums"
+}
+
+// SmartAlbumRules is the filter expression of a smart album.
+// All set rules must match (logical AND); unset rules are ignored.
+type SmartAlbumRules struct {
+	MediaType   string      `json:"type,omitempty"`        // "photo", "video", "document", "audio", or "" for all
+	UploaderIDs []uuid.UUID `json:"uploaderIds,omitempty"` // Any of these uploaders
+	TakenAfter  *time.Time  `json:"takenAfter,omitempty"`  // Inclusive
+	TakenBefore *time.Time  `json:"takenBefore,omitempty"` // Exclusive
+	HasLocation *bool       `json:"hasLocation,omitempty"`
+	Bounds      *GeoBounds  `json:"bounds,omitempty"`
+	CameraMake  string      `json:"cameraMake,omitempty"`
+	CameraModel string      `json:"cameraModel,omitempty"`
+}
+
+// GeoBounds is a latitude/longitude bounding box
+type GeoBounds struct {
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+// Validate checks that the rules are well-formed.
+// Returned errors wrap ErrInvalidInput.
+func (r SmartAlbumRules) Validate() error {
+	switch r.MediaType {
+	case "", "photo", "video", "document", "audio":
+	default:
+		return fmt.Errorf("%w: unknown media type %q", ErrInvalidInput, r.MediaType)
+	}
+
+	if r.TakenAfter != nil && r.TakenBefore != nil && !r.TakenAfter.Before(*r.TakenBefore) {
+		return fmt.Errorf("%w: takenAfter must be before takenBefore", ErrInvalidInput)
+	}
+
+	if r.Bounds != nil {
+		b := r.Bounds
+		if b.MinLat < -90 || b.MaxLat > 90 || b.MinLat > b.MaxLat {
+			return fmt.Errorf("%w: invalid latitude bounds", ErrInvalidInput)
+		}
+		if b.MinLng < -180 || b.MaxLng > 180 || b.MinLng > b.MaxLng {
+			return fmt.Errorf("%w: invalid longitude bounds", ErrInvalidInput)
+		}
+		if r.HasLocation != nil && !*r.HasLocation {
+			return fmt.Errorf("%w: bounds require hasLocation", ErrInvalidInput)
+		}
+	}
+
+	for _, id := range r.UploaderIDs {
+		if id == uuid.Nil {
+			return fmt.Errorf("%w: invalid uploader id", ErrInvalidInput)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the album's name and rules.
+// Returned errors wrap ErrInvalidInput.
+func (a *SmartAlbum) Validate() error {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if len(a.Name) > 255 {
+		return fmt.Errorf("%w: name is too long", ErrInvalidInput)
+	}
+	return a.Rules.Validate()
+}
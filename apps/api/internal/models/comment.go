@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxCommentLength is the maximum comment body length in characters
+const MaxCommentLength = 4000
+
+// MaxEmojiBytes bounds the size of a reaction (allows multi-codepoint emoji)
+const MaxEmojiBytes = 32
+
+// Comment is a message on a media item. Replies reference their parent comment.
+type Comment struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	MediaID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"mediaId"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parentId,omitempty"`
+	AuthorID  *uuid.UUID `gorm:"type:uuid" json:"authorId,omitempty"`
+	Author    *User      `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Body      string     `gorm:"not null" json:"body"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for GORM
+func (Comment) TableName() string {
+	return "media_comments"
+}
+
+// Validate normalizes and checks the comment body.
+// Returned errors wrap ErrInvalidInput.
+func (c *Comment) Validate() error {
+	c.Body = strings.TrimSpace(c.Body)
+	if c.Body == "" {
+		return fmt.Errorf("%w: comment body is required", ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(c.Body) > MaxCommentLength {
+		return fmt.Errorf("%w: comment is longer than %d characters", ErrInvalidInput, MaxCommentLength)
+	}
+	return nil
+}
+
+// Reaction is a single user's emoji reaction to a media item
+type Reaction struct {
+	MediaID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"mediaId"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
+	Emoji     string    `gorm:"primaryKey" json:"emoji"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// TableName specifies the table name for GORM
+func (Reaction) TableName() string {
+	return "media_reactions"
+}
+
+// ValidateEmoji checks that a reaction is a short, printable, whitespace-free string.
+// Returned errors wrap ErrInvalidInput.
+func ValidateEmoji(emoji string) error {
+	if emoji == "" || len(emoji) > MaxEmojiBytes || !utf8.ValidString(emoji) {
+		return fmt.Errorf("%w: invalid emoji", ErrInvalidInput)
+	}
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("%w: invalid emoji", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// ReactionSummary aggregates the reactions on an item by emoji
+type ReactionSummary struct {
+	Emoji   string      `json:"emoji"`
+	Count   int         `json:"count"`
+	UserIDs []uuid.UUID `json:"userIds"`
+}
@@ -4,5 +4,8 @@ import "errors"
 
 // Common domain errors
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrPasswordRequired = errors.New("password required")
+	ErrTooManyAttempts  = errors.New("too many attempts")
 )
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// HouseholdExportVersion is the current version of the export archive format
+const HouseholdExportVersion = 1
+
+// HouseholdExport is the manifest of a household export archive.
+// It holds every database record needed to restore the household; the
+// original files are stored alongside it under "originals/<Path>".
+type HouseholdExport struct {
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exportedAt"`
+	Household  Household   `json:"household"`
+	Users      []User      `json:"users"`
+	Items      []MediaItem `json:"items"`
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type MediaItem struct {
@@ -12,7 +13,7 @@ type MediaItem struct {
 	UploaderID  *uuid.UUID `gorm:"type:uuid;index" json:"uploaderId,omitempty"`
 	IsPrivate   bool       `gorm:"default:false" json:"isPrivate"`
 	Path        string     `gorm:"size:512;not null" json:"path"`
-	Type        string     `gorm:"size:10;not null" json:"type"` // "photo" or "video"
+	Type        string     `gorm:"size:10;not null" json:"type"` // "photo", "video", "document", or "audio"
 	MimeType    string     `gorm:"size:100" json:"mimeType,omitempty"`
 	SizeBytes   int64      `json:"sizeBytes,omitempty"`
 	SHA256      string     `gorm:"size:64" json:"sha256,omitempty"`
@@ -20,15 +21,32 @@ type MediaItem struct {
 	Width       int        `json:"width,omitempty"`
 	Height      int        `json:"height,omitempty"`
 	DurationSec int        `json:"durationSec,omitempty"`
+	ArchivedAt  *time.Time `gorm:"index" json:"archivedAt,omitempty"` // Hidden from the main timeline when set
 	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
 
-	// Preview, thumbnail, web-optimized, and original file paths
+	// Set when the item is in the trash; GORM excludes trashed rows unless Unscoped
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+
+	// Preview, thumbnail, web-optimized (WebP and AVIF for photos, H.264 MP4 for
+	// videos, AAC for audio), and original file paths
 	PreviewPath      string `gorm:"size:512" json:"previewPath,omitempty"`
 	ThumbnailPath    string `gorm:"size:512" json:"thumbnailPath,omitempty"`
 	WebPath          string `gorm:"size:512" json:"webPath,omitempty"`
+	AvifPath         string `gorm:"size:512" json:"avifPath,omitempty"`
 	OriginalFilename string `gorm:"size:255" json:"originalFilename,omitempty"`
 
+	// Animated GIF/WebP details. AnimatedThumbnailPath is a looping GIF
+	// thumbnail for animations, or a short silent MP4 hover preview for videos.
+	IsAnimated            bool   `gorm:"not null;default:false" json:"isAnimated"`
+	FrameCount            int    `json:"frameCount,omitempty"`
+	AnimationDurationMs   int    `json:"animationDurationMs,omitempty"` // Length of one loop
+	AnimatedThumbnailPath string `gorm:"size:512" json:"animatedThumbnailPath,omitempty"`
+
+	// Low-resolution placeholder shown while the thumbnail loads
+	BlurHash      string `gorm:"size:64" json:"blurHash,omitempty"`
+	DominantColor string `gorm:"size:7" json:"dominantColor,omitempty"` // "#rrggbb"
+
 	// Camera metadata (from EXIF)
 	CameraMake  string `gorm:"size:100" json:"cameraMake,omitempty"`
 	CameraModel string `gorm:"size:100" json:"cameraModel,omitempty"`
@@ -43,6 +61,89 @@ type MediaItem struct {
 	FNumber      *float64 `json:"fNumber,omitempty"`
 	ExposureTime string   `gorm:"size:20" json:"exposureTime,omitempty"`
 	FocalLength  *float64 `json:"focalLength,omitempty"`
+
+	// Video technical metadata (from ffprobe)
+	Rotation   int     `json:"rotation,omitempty"` // Clockwise degrees; Width/Height are already rotated
+	VideoCodec string  `gorm:"size:32" json:"videoCodec,omitempty"`
+	Bitrate    int64   `json:"bitrate,omitempty"` // Bits per second
+	FrameRate  float64 `json:"frameRate,omitempty"`
+
+	// Audio metadata (from ffprobe tags)
+	AudioCodec string `gorm:"size:32" json:"audioCodec,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+
+	// Number of pages, for documents
+	PageCount int `json:"pageCount,omitempty"`
+
+	// Timestamp of the frame chosen as the video's thumbnail; nil means the default
+	PosterTimeSec *float64 `json:"posterTimeSec,omitempty"`
+
+	// Apple Live Photos. The still and its motion video share a content
+	// identifier; the still links to the video, and the video (hidden from
+	// listings) links back to the still.
+	ContentIdentifier string     `gorm:"size:64" json:"contentIdentifier,omitempty"`
+	LivePhotoVideoID  *uuid.UUID `gorm:"type:uuid" json:"livePhotoVideoId,omitempty"`
+	LivePhotoID       *uuid.UUID `gorm:"type:uuid" json:"livePhotoId,omitempty"`
+
+	// RAW+JPEG pairs are stacked: the JPEG links to the RAW file, and the RAW
+	// file (hidden from listings) links back to the JPEG.
+	StackedRawID  *uuid.UUID `gorm:"type:uuid" json:"stackedRawId,omitempty"`
+	StackParentID *uuid.UUID `gorm:"type:uuid" json:"stackParentId,omitempty"`
+
+	// Web rendition (WebPath) of a video, transcoded in the background
+	WebVideoStatus string `gorm:"size:16" json:"webVideoStatus,omitempty"`
+
+	// HLS adaptive stream for long videos, generated in the background.
+	// HLSPath is the master playlist; HLSProgress is a percentage.
+	HLSStatus   string `gorm:"size:16" json:"hlsStatus,omitempty"`
+	HLSProgress int    `gorm:"not null;default:0" json:"hlsProgress,omitempty"`
+	HLSPath     string `gorm:"size:512" json:"hlsPath,omitempty"`
+
+	// Caller's own state, read from media_user_states (never written via this struct)
+	IsFavorite bool `gorm:"->" json:"isFavorite"`
+	Rating     int  `gorm:"->" json:"rating"`
+
+	// Number of comments on the item, computed on read
+	CommentCount int `gorm:"->" json:"commentCount"`
+
+	// Signed, short-lived URLs for <img>/<video> tags; set per response
+	URLs *MediaURLs `gorm:"-" json:"urls,omitempty"`
+}
+
+// Derivative kinds that can be served through signed URLs.
+// MediaKindTrashThumbnail is the thumbnail of an item in the trash.
+const (
+	MediaKindThumbnail      = "thumbnail"
+	MediaKindDownload       = "download"
+	MediaKindOriginal       = "original"
+	MediaKindTrashThumbnail = "trash-thumbnail"
+)
+
+// HLS stream generation states
+const (
+	HLSStatusPending    = "pending"
+	HLSStatusProcessing = "processing"
+	HLSStatusReady      = "ready"
+	HLSStatusFailed     = "failed"
+)
+
+// Web video rendition states; there is no "ready" state, as WebPath is set
+// and the status cleared when the rendition is saved
+const (
+	WebVideoStatusPending    = "pending"
+	WebVideoStatusProcessing = "processing"
+	WebVideoStatusFailed     = "failed"
+)
+
+// MediaURLs are signed URLs for an item's derivatives. They need no
+// Authorization header and stop working at ExpiresAt.
+type MediaURLs struct {
+	Thumbnail string    `json:"thumbnail,omitempty"`
+	Download  string    `json:"download,omitempty"` // Not set for trashed items
+	Original  string    `json:"original,omitempty"` // Not set for trashed items
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 // TableName specifies the table name for GORM
@@ -50,6 +151,34 @@ func (MediaItem) TableName() string {
 	return "storage_items"
 }
 
+// MaxRating is the highest star rating a user can give an item
+const MaxRating = 5
+
+// MediaUserState holds a single user's favorite flag and star rating for an item
+type MediaUserState struct {
+	MediaID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"mediaId"`
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"userId"`
+	IsFavorite bool      `gorm:"not null" json:"isFavorite"`
+	Rating     int       `gorm:"not null" json:"rating"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName specifies the table name for GORM
+func (MediaUserState) TableName() string {
+	return "media_user_states"
+}
+
+// MediaDocumentText is the text extracted from a document, used for search
+type MediaDocumentText struct {
+	MediaID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Content string    `gorm:"not null"`
+}
+
+// TableName specifies the table name for GORM
+func (MediaDocumentText) TableName() string {
+	return "media_document_texts"
+}
+
 // MediaListResponse is the API response for listing media
 type MediaListResponse struct {
 	Items      []MediaItem `json:"items"`
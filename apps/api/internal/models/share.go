@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxShareItems limits how many items a single share link can contain
+const MaxShareItems = 500
+
+// ShareLink gives unauthenticated access to a fixed set of media items
+// through an unguessable token.
+type ShareLink struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	HouseholdID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"householdId"`
+	CreatedBy     *uuid.UUID `gorm:"type:uuid" json:"createdBy,omitempty"`
+	Token         string     `gorm:"not null;uniqueIndex" json:"token"`
+	Title         string     `json:"title,omitempty"`
+	PasswordHash  string     `json:"-"` // bcrypt; empty when no password is set
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	AllowOriginal bool       `gorm:"not null" json:"allowOriginal"`
+	ViewCount     int64      `gorm:"not null" json:"viewCount"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+
+	HasPassword bool        `gorm:"-" json:"hasPassword"`
+	MediaIDs    []uuid.UUID `gorm:"-" json:"mediaIds,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (ShareLink) TableName() string {
+	return "share_links"
+}
+
+// AfterFind sets computed fields after loading from the database
+func (l *ShareLink) AfterFind(tx *gorm.DB) error {
+	l.HasPassword = l.PasswordHash != ""
+	return nil
+}
+
+// IsActive reports whether the link can currently be used
+func (l *ShareLink) IsActive(now time.Time) bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
+
+// ShareLinkItem is a media item included in a share link
+type ShareLinkItem struct {
+	LinkID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	MediaID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Position int       `gorm:"not null"`
+}
+
+// TableName specifies the table name for GORM
+func (ShareLinkItem) TableName() string {
+	return "share_link_items"
+}
@@ -6,6 +6,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// Role values for User.Role
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
 type User struct {
 	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	HouseholdID uuid.UUID `gorm:"type:uuid;not null;index" json:"householdId"`
@@ -23,6 +29,11 @@ func (User) TableName() string {
 	return "users"
 }
 
+// IsAdmin reports whether the user has the household admin role
+func (u *User) IsAdmin() bool {
+	return u != nil && u.Role == RoleAdmin
+}
+
 type Household struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	Name      string    `gorm:"size:255;not null" json:"name"`
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"storage-api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SmartAlbumRepository defines the interface for smart album data access
+type SmartAlbumRepository interface {
+	Create(ctx context.Context, album *models.SmartAlbum) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.SmartAlbum, error)
+	List(ctx context.Context, householdID uuid.UUID) ([]models.SmartAlbum, error)
+	Update(ctx context.Context, album *models.SmartAlbum) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type smartAlbumRepo struct {
+	db *gorm.DB
+}
+
+// NewSmartAlbumRepository creates a new SmartAlbumRepository
+func NewSmartAlbumRepository(db *gorm.DB) SmartAlbumRepository {
+	return &smartAlbumRepo{db: db}
+}
+
+func (r *smartAlbumRepo) Create(ctx context.Context, album *models.SmartAlbum) error {
+	return r.db.WithContext(ctx).Create(album).Error
+}
+
+func (r *smartAlbumRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.SmartAlbum, error) {
+	var album models.SmartAlbum
+	err := r.db.WithContext(ctx).First(&album, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+func (r *smartAlbumRepo) List(ctx context.Context, householdID uuid.UUID) ([]models.SmartAlbum, error) {
+	var albums []models.SmartAlbum
+	err := r.db.WithContext(ctx).
+		Where("household_id = ?", householdID).
+		Order("name").
+		Find(&albums).Error
+	if err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+func (r *smartAlbumRepo) Update(ctx context.Context, album *models.SmartAlbum) error {
+	result := r.db.WithContext(ctx).
+		Model(album).
+		Select("name", "rules").
+		Updates(album)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *smartAlbumRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&models.SmartAlbum{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"storage-api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CommentRepository defines the interface for comment and reaction data access
+type CommentRepository interface {
+	Create(ctx context.Context, comment *models.Comment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Comment, error)
+	ListByMedia(ctx context.Context, mediaID uuid.UUID) ([]models.Comment, error)
+	UpdateBody(ctx context.Context, id uuid.UUID, body string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	ListReactions(ctx context.Context, mediaID uuid.UUID) ([]models.Reaction, error)
+	AddReaction(ctx context.Context, reaction *models.Reaction) error
+	RemoveReaction(ctx context.Context, mediaID, userID uuid.UUID, emoji string) error
+}
+
+type commentRepo struct {
+	db *gorm.DB
+}
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepo{db: db}
+}
+
+func (r *commentRepo) Create(ctx context.Context, comment *models.Comment) error {
+	return r.db.WithContext(ctx).Omit("Author").Create(comment).Error
+}
+
+func (r *commentRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Comment, error) {
+	var comment models.Comment
+	err := r.db.WithContext(ctx).Preload("Author").First(&comment, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (r *commentRepo) ListByMedia(ctx context.Context, mediaID uuid.UUID) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.WithContext(ctx).
+		Preload("Author").
+		Where("media_id = ?", mediaID).
+		Order("created_at").
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *commentRepo) UpdateBody(ctx context.Context, id uuid.UUID, body string) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.Comment{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"body": body, "edited_at": time.Now().UTC()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *commentRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&models.Comment{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *commentRepo) ListReactions(ctx context.Context, mediaID uuid.UUID) ([]models.Reaction, error) {
+	var reactions []models.Reaction
+	err := r.db.WithContext(ctx).
+		Where("media_id = ?", mediaID).
+		Order("created_at").
+		Find(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+func (r *commentRepo) AddReaction(ctx context.Context, reaction *models.Reaction) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(reaction).Error
+}
+
+func (r *commentRepo) RemoveReaction(ctx context.Context, mediaID, userID uuid.UUID, emoji string) error {
+	return r.db.WithContext(ctx).
+		Delete(&models.Reaction{}, "media_id = ? AND user_id = ? AND emoji = ?", mediaID, userID, emoji).Error
+}
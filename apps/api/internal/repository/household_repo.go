@@ -5,12 +5,15 @@ import (
 
 	"storage-api/internal/models"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // HouseholdRepository defines the interface for household data access
 type HouseholdRepository interface {
 	List(ctx context.Context) ([]models.Household, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Household, error)
+	Create(ctx context.Context, household *models.Household) error
 }
 
 type householdRepo struct {
@@ -30,3 +33,19 @@ func (r *householdRepo) List(ctx context.Context) ([]models.Household, error) {
 	}
 	return households, nil
 }
+
+func (r *householdRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Household, error) {
+	var household models.Household
+	err := r.db.WithContext(ctx).First(&household, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+func (r *householdRepo) Create(ctx context.Context, household *models.Household) error {
+	return r.db.WithContext(ctx).Create(household).Error
+}
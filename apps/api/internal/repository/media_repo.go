@@ -2,21 +2,45 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"strings"
+	"time"
 
 	"storage-api/internal/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// commentCountColumn selects the number of comments on each listed item
+const commentCountColumn = "(SELECT COUNT(*) FROM media_comments c WHERE c.media_id = storage_items.id) AS comment_count"
+
 // MediaListFilter contains filter options for listing media
 type MediaListFilter struct {
 	HouseholdID uuid.UUID
 	UserID      *uuid.UUID // Current user's ID for visibility filtering
 	Visibility  string     // "all", "mine", or "public"
-	MediaType   string     // "photo", "video", or "" for all
+	MediaType   string     // "photo", "video", "document", "audio", or "" for all
+	Sort        string     // "date" (default), "rating", or "favorite"
+	Archived    string     // "" excludes archived items, "true" only archived, "all" both
+	Trashed     bool       // List items in the trash instead of live items
+	Search      string     // Case-insensitive match on filename, camera, audio tags, and document text
 	Page        int
 	PageSize    int
+
+	// Per-user state filters; require UserID
+	FavoritesOnly bool
+	MinRating     int
+
+	// Rule filters (used by smart albums); zero values are ignored
+	UploaderIDs []uuid.UUID
+	TakenAfter  *time.Time // Inclusive, compared against COALESCE(taken_at, created_at)
+	TakenBefore *time.Time // Exclusive
+	HasLocation *bool
+	Bounds      *models.GeoBounds
+	CameraMake  string // Case-insensitive exact match
+	CameraModel string // Case-insensitive exact match
 }
 
 // MediaRepository defines the interface for media data access
@@ -24,8 +48,31 @@ type MediaRepository interface {
 	Create(ctx context.Context, item *models.MediaItem) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.MediaItem, error)
 	GetByPath(ctx context.Context, householdID uuid.UUID, path string) (*models.MediaItem, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]models.MediaItem, error)
+	GetByIDsIncludingTrash(ctx context.Context, ids []uuid.UUID) ([]models.MediaItem, error)
+	GetBySHA256(ctx context.Context, householdID uuid.UUID, sha256 string) (*models.MediaItem, error)
+	ListAllByHousehold(ctx context.Context, householdID uuid.UUID) ([]models.MediaItem, error)
 	List(ctx context.Context, filter MediaListFilter) ([]models.MediaItem, int64, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	GetTrashedByID(ctx context.Context, id uuid.UUID) (*models.MediaItem, error)
+	ListTrashed(ctx context.Context, householdID uuid.UUID, uploaderID *uuid.UUID) ([]models.MediaItem, error)
+	ListTrashedBefore(ctx context.Context, cutoff time.Time) ([]models.MediaItem, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	Purge(ctx context.Context, ids []uuid.UUID) (int64, error)
+	SetArchived(ctx context.Context, ids []uuid.UUID, archived bool) (int64, error)
+	UpdateFields(ctx context.Context, id uuid.UUID, fields map[string]any) error
+	NextHLSCandidate(ctx context.Context, minDurationSec int) (*models.MediaItem, error)
+	FindLivePhotoCandidates(ctx context.Context, householdID uuid.UUID, mediaType, contentID, filenameBase string) ([]models.MediaItem, error)
+	FindStackCandidates(ctx context.Context, householdID uuid.UUID, filenameBase string) ([]models.MediaItem, error)
+	ResetHLSProcessing(ctx context.Context) (int64, error)
+	NextWebVideoCandidate(ctx context.Context) (*models.MediaItem, error)
+	ResetWebVideoProcessing(ctx context.Context) (int64, error)
+	SetDocumentText(ctx context.Context, mediaID uuid.UUID, content string) error
+	Transaction(ctx context.Context, fn func(repo MediaRepository) error) error
+
+	GetUserState(ctx context.Context, mediaID, userID uuid.UUID) (*models.MediaUserState, error)
+	SetFavorite(ctx context.Context, mediaID, userID uuid.UUID, favorite bool) error
+	SetRating(ctx context.Context, mediaID, userID uuid.UUID, rating int) error
 }
 
 type mediaRepo struct {
@@ -43,7 +90,9 @@ func (r *mediaRepo) Create(ctx context.Context, item *models.MediaItem) error {
 
 func (r *mediaRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.MediaItem, error) {
 	var item models.MediaItem
-	err := r.db.WithContext(ctx).First(&item, "id = ?", id).Error
+	err := r.db.WithContext(ctx).
+		Select("storage_items.*, "+commentCountColumn).
+		First(&item, "id = ?", id).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, models.ErrNotFound
 	}
@@ -55,7 +104,8 @@ func (r *mediaRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.MediaIte
 
 func (r *mediaRepo) GetByPath(ctx context.Context, householdID uuid.UUID, path string) (*models.MediaItem, error) {
 	var item models.MediaItem
-	err := r.db.WithContext(ctx).
+	// Include trashed items: the path stays taken until the item is purged
+	err := r.db.WithContext(ctx).Unscoped().
 		Where("household_id = ? AND path = ?", householdID, path).
 		First(&item).Error
 	if err == gorm.ErrRecordNotFound {
@@ -67,12 +117,72 @@ func (r *mediaRepo) GetByPath(ctx context.Context, householdID uuid.UUID, path s
 	return &item, nil
 }
 
+func (r *mediaRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+	if len(ids) == 0 {
+		return items, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *mediaRepo) GetByIDsIncludingTrash(ctx context.Context, ids []uuid.UUID) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+	if len(ids) == 0 {
+		return items, nil
+	}
+	err := r.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetBySHA256 finds an item (including trashed ones) with the given content hash
+func (r *mediaRepo) GetBySHA256(ctx context.Context, householdID uuid.UUID, sha256 string) (*models.MediaItem, error) {
+	var item models.MediaItem
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("household_id = ? AND sha256 = ?", householdID, sha256).
+		First(&item).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ListAllByHousehold returns every item of a household, including archived and
+// trashed ones, oldest first
+func (r *mediaRepo) ListAllByHousehold(ctx context.Context, householdID uuid.UUID) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("household_id = ?", householdID).
+		Order("created_at, id").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 func (r *mediaRepo) List(ctx context.Context, filter MediaListFilter) ([]models.MediaItem, int64, error) {
 	var items []models.MediaItem
 	var total int64
 
 	db := r.db.WithContext(ctx).Model(&models.MediaItem{}).Where("household_id = ?", filter.HouseholdID)
 
+	// Live Photo videos and stacked RAW files are shown through their still
+	db = db.Where("storage_items.live_photo_id IS NULL AND storage_items.stack_parent_id IS NULL")
+
+	if filter.Trashed {
+		db = db.Unscoped().Where("storage_items.deleted_at IS NOT NULL")
+	}
+
 	// Apply visibility filter
 	switch filter.Visibility {
 	case "mine":
@@ -98,12 +208,67 @@ func (r *mediaRepo) List(ctx context.Context, filter MediaListFilter) ([]models.
 		db = db.Where("type = ?", filter.MediaType)
 	}
 
+	// Apply archive filter (the trash shows archived and unarchived items alike)
+	switch {
+	case filter.Trashed, filter.Archived == "all":
+		// Include both archived and unarchived items
+	case filter.Archived == "true":
+		db = db.Where("storage_items.archived_at IS NOT NULL")
+	default:
+		db = db.Where("storage_items.archived_at IS NULL")
+	}
+
+	// Apply text search
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		db = db.Where("original_filename ILIKE @q OR camera_make ILIKE @q OR camera_model ILIKE @q OR "+
+			"title ILIKE @q OR artist ILIKE @q OR album ILIKE @q OR "+
+			"EXISTS (SELECT 1 FROM media_document_texts dt WHERE dt.media_id = storage_items.id AND dt.content ILIKE @q)",
+			sql.Named("q", pattern))
+	}
+
+	db = applyRuleFilters(db, filter)
+
+	// Join the caller's favorite/rating state in the same query (one row per item)
+	if filter.UserID != nil {
+		db = db.Joins("LEFT JOIN media_user_states mus ON mus.media_id = storage_items.id AND mus.user_id = ?", *filter.UserID)
+		if filter.FavoritesOnly {
+			db = db.Where("mus.is_favorite")
+		}
+		if filter.MinRating > 0 {
+			db = db.Where("mus.rating >= ?", filter.MinRating)
+		}
+	} else if filter.FavoritesOnly || filter.MinRating > 0 {
+		// Per-user filters cannot match without a user
+		db = db.Where("FALSE")
+	}
+
 	if err := db.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	// Select item columns plus computed per-request fields
+	columns := []string{"storage_items.*", commentCountColumn}
+	if filter.UserID != nil {
+		columns = append(columns, "COALESCE(mus.is_favorite, false) AS is_favorite", "COALESCE(mus.rating, 0) AS rating")
+		switch filter.Sort {
+		case "rating":
+			db = db.Order("COALESCE(mus.rating, 0) DESC")
+		case "favorite":
+			db = db.Order("COALESCE(mus.is_favorite, false) DESC")
+		}
+	}
+	db = db.Select(strings.Join(columns, ", "))
+
+	if filter.Trashed {
+		db = db.Order("storage_items.deleted_at DESC")
+	}
+
+	// The ID makes the order total, so items sharing a timestamp (bursts,
+	// imports without EXIF) are neither repeated nor skipped across pages
 	offset := (filter.Page - 1) * filter.PageSize
-	err := db.Order("COALESCE(taken_at, created_at) DESC").
+	err := db.Order("COALESCE(storage_items.taken_at, storage_items.created_at) DESC").
+		Order("storage_items.id DESC").
 		Offset(offset).
 		Limit(filter.PageSize).
 		Find(&items).Error
@@ -115,8 +280,249 @@ func (r *mediaRepo) List(ctx context.Context, filter MediaListFilter) ([]models.
 	return items, total, nil
 }
 
+// applyRuleFilters adds the optional rule-based conditions of the filter
+func applyRuleFilters(db *gorm.DB, filter MediaListFilter) *gorm.DB {
+	if len(filter.UploaderIDs) > 0 {
+		db = db.Where("uploader_id IN ?", filter.UploaderIDs)
+	}
+	if filter.TakenAfter != nil {
+		db = db.Where("COALESCE(storage_items.taken_at, storage_items.created_at) >= ?", *filter.TakenAfter)
+	}
+	if filter.TakenBefore != nil {
+		db = db.Where("COALESCE(storage_items.taken_at, storage_items.created_at) < ?", *filter.TakenBefore)
+	}
+	if filter.HasLocation != nil {
+		if *filter.HasLocation {
+			db = db.Where("latitude IS NOT NULL AND longitude IS NOT NULL")
+		} else {
+			db = db.Where("latitude IS NULL OR longitude IS NULL")
+		}
+	}
+	if b := filter.Bounds; b != nil {
+		db = db.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?",
+			b.MinLat, b.MaxLat, b.MinLng, b.MaxLng)
+	}
+	if filter.CameraMake != "" {
+		db = db.Where("LOWER(camera_make) = LOWER(?)", filter.CameraMake)
+	}
+	if filter.CameraModel != "" {
+		db = db.Where("LOWER(camera_model) = LOWER(?)", filter.CameraModel)
+	}
+	return db
+}
+
+// escapeLike escapes LIKE wildcard characters in user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Delete trashes an item along with its Live Photo video and stacked RAW file, if any
 func (r *mediaRepo) Delete(ctx context.Context, id uuid.UUID) error {
-	result := r.db.WithContext(ctx).Delete(&models.MediaItem{}, "id = ?", id)
+	result := r.db.WithContext(ctx).Delete(&models.MediaItem{}, "id = ? OR live_photo_id = ? OR stack_parent_id = ?", id, id, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *mediaRepo) GetTrashedByID(ctx context.Context, id uuid.UUID) (*models.MediaItem, error) {
+	var item models.MediaItem
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL").
+		First(&item, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *mediaRepo) ListTrashed(ctx context.Context, householdID uuid.UUID, uploaderID *uuid.UUID) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+	db := r.db.WithContext(ctx).Unscoped().
+		Where("household_id = ? AND deleted_at IS NOT NULL", householdID)
+	if uploaderID != nil {
+		db = db.Where("uploader_id = ?", *uploaderID)
+	}
+	if err := db.Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *mediaRepo) ListTrashedBefore(ctx context.Context, cutoff time.Time) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Restore untrashes an item along with its Live Photo video and stacked RAW file, if any
+func (r *mediaRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Unscoped().
+		Model(&models.MediaItem{}).
+		Where("(id = ? OR live_photo_id = ? OR stack_parent_id = ?) AND deleted_at IS NOT NULL", id, id, id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// Purge permanently deletes trashed rows. Items that are not in the trash are left alone.
+func (r *mediaRepo) Purge(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("id IN ? AND deleted_at IS NOT NULL", ids).
+		Delete(&models.MediaItem{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.RowsAffected, result.Error
+	}
+
+	// Unlink Live Photo halves and stacks that pointed at purged items
+	for _, column := range []string{"live_photo_video_id", "live_photo_id", "stacked_raw_id", "stack_parent_id"} {
+		err := r.db.WithContext(ctx).Unscoped().
+			Model(&models.MediaItem{}).
+			Where(column+" IN ?", ids).
+			Update(column, nil).Error
+		if err != nil {
+			return result.RowsAffected, err
+		}
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *mediaRepo) SetArchived(ctx context.Context, ids []uuid.UUID, archived bool) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var archivedAt any
+	if archived {
+		archivedAt = gorm.Expr("COALESCE(archived_at, now())")
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.MediaItem{}).
+		Where("id IN ?", ids).
+		Update("archived_at", archivedAt)
+	return result.RowsAffected, result.Error
+}
+
+// NextHLSCandidate returns the newest video at least minDurationSec long that
+// is queued for (or has never had) an HLS stream
+func (r *mediaRepo) NextHLSCandidate(ctx context.Context, minDurationSec int) (*models.MediaItem, error) {
+	var item models.MediaItem
+	err := r.db.WithContext(ctx).
+		Where("type = ? AND duration_sec >= ?", "video", minDurationSec).
+		Where("hls_status IS NULL OR hls_status IN ?", []string{"", models.HLSStatusPending}).
+		Order("created_at DESC").
+		First(&item).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindLivePhotoCandidates returns unpaired items of mediaType in a household
+// whose content identifier is contentID or whose original file name is
+// filenameBase with any extension (case-insensitively)
+func (r *mediaRepo) FindLivePhotoCandidates(ctx context.Context, householdID uuid.UUID, mediaType, contentID, filenameBase string) ([]models.MediaItem, error) {
+	db := r.db.WithContext(ctx).
+		Where("household_id = ? AND type = ?", householdID, mediaType).
+		Where("live_photo_id IS NULL AND live_photo_video_id IS NULL")
+
+	switch {
+	case contentID != "" && filenameBase != "":
+		db = db.Where("content_identifier = ? OR original_filename ILIKE ?", contentID, escapeLike(filenameBase)+".%")
+	case contentID != "":
+		db = db.Where("content_identifier = ?", contentID)
+	case filenameBase != "":
+		db = db.Where("original_filename ILIKE ?", escapeLike(filenameBase)+".%")
+	default:
+		return nil, nil
+	}
+
+	var items []models.MediaItem
+	if err := db.Limit(20).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FindStackCandidates returns unstacked photos in a household whose original
+// file name is filenameBase with any extension (case-insensitively)
+func (r *mediaRepo) FindStackCandidates(ctx context.Context, householdID uuid.UUID, filenameBase string) ([]models.MediaItem, error) {
+	var items []models.MediaItem
+	err := r.db.WithContext(ctx).
+		Where("household_id = ? AND type = ?", householdID, "photo").
+		Where("stacked_raw_id IS NULL AND stack_parent_id IS NULL").
+		Where("original_filename ILIKE ?", escapeLike(filenameBase)+".%").
+		Limit(20).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ResetHLSProcessing requeues items whose HLS generation was interrupted
+func (r *mediaRepo) ResetHLSProcessing(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Model(&models.MediaItem{}).
+		Where("hls_status = ?", models.HLSStatusProcessing).
+		Updates(map[string]any{"hls_status": models.HLSStatusPending, "hls_progress": 0})
+	return result.RowsAffected, result.Error
+}
+
+// NextWebVideoCandidate returns the newest video queued for a web rendition
+func (r *mediaRepo) NextWebVideoCandidate(ctx context.Context) (*models.MediaItem, error) {
+	var item models.MediaItem
+	err := r.db.WithContext(ctx).
+		Where("web_video_status = ?", models.WebVideoStatusPending).
+		Order("created_at DESC").
+		First(&item).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ResetWebVideoProcessing requeues items whose web rendition was interrupted
+func (r *mediaRepo) ResetWebVideoProcessing(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Model(&models.MediaItem{}).
+		Where("web_video_status = ?", models.WebVideoStatusProcessing).
+		Update("web_video_status", models.WebVideoStatusPending)
+	return result.RowsAffected, result.Error
+}
+
+// UpdateFields updates the given columns of a single live (non-trashed) item
+func (r *mediaRepo) UpdateFields(ctx context.Context, id uuid.UUID, fields map[string]any) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.MediaItem{}).
+		Where("id = ?", id).
+		Updates(fields)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -125,3 +531,53 @@ func (r *mediaRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	}
 	return nil
 }
+
+// Transaction runs fn with a repository bound to a single database transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (r *mediaRepo) Transaction(ctx context.Context, fn func(repo MediaRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&mediaRepo{db: tx})
+	})
+}
+
+func (r *mediaRepo) GetUserState(ctx context.Context, mediaID, userID uuid.UUID) (*models.MediaUserState, error) {
+	var state models.MediaUserState
+	err := r.db.WithContext(ctx).
+		Where("media_id = ? AND user_id = ?", mediaID, userID).
+		First(&state).Error
+	if err == gorm.ErrRecordNotFound {
+		// No row means the defaults: not a favorite, unrated
+		return &models.MediaUserState{MediaID: mediaID, UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *mediaRepo) SetFavorite(ctx context.Context, mediaID, userID uuid.UUID, favorite bool) error {
+	state := models.MediaUserState{MediaID: mediaID, UserID: userID, IsFavorite: favorite}
+	return r.upsertUserState(ctx, &state, "is_favorite")
+}
+
+func (r *mediaRepo) SetRating(ctx context.Context, mediaID, userID uuid.UUID, rating int) error {
+	state := models.MediaUserState{MediaID: mediaID, UserID: userID, Rating: rating}
+	return r.upsertUserState(ctx, &state, "rating")
+}
+
+// SetDocumentText stores the searchable text of a document, replacing any
+// previously extracted text
+func (r *mediaRepo) SetDocumentText(ctx context.Context, mediaID uuid.UUID, content string) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "media_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content"}),
+	}).Create(&models.MediaDocumentText{MediaID: mediaID, Content: content}).Error
+}
+
+// upsertUserState inserts the state row or updates only the given column if it exists
+func (r *mediaRepo) upsertUserState(ctx context.Context, state *models.MediaUserState, column string) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "media_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{column, "updated_at"}),
+	}).Create(state).Error
+}
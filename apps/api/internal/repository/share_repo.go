@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"storage-api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ShareLinkRepository defines the interface for share link data access
+type ShareLinkRepository interface {
+	Create(ctx context.Context, link *models.ShareLink) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.ShareLink, error)
+	GetByToken(ctx context.Context, token string) (*models.ShareLink, error)
+	List(ctx context.Context, householdID uuid.UUID, createdBy *uuid.UUID) ([]models.ShareLink, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	IncrementViews(ctx context.Context, id uuid.UUID) error
+}
+
+type shareLinkRepo struct {
+	db *gorm.DB
+}
+
+// NewShareLinkRepository creates a new ShareLinkRepository
+func NewShareLinkRepository(db *gorm.DB) ShareLinkRepository {
+	return &shareLinkRepo{db: db}
+}
+
+// Create saves the link and its items (link.MediaIDs, in order) in one transaction
+func (r *shareLinkRepo) Create(ctx context.Context, link *models.ShareLink) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(link).Error; err != nil {
+			return err
+		}
+
+		items := make([]models.ShareLinkItem, len(link.MediaIDs))
+		for i, mediaID := range link.MediaIDs {
+			items[i] = models.ShareLinkItem{LinkID: link.ID, MediaID: mediaID, Position: i}
+		}
+		return tx.Create(&items).Error
+	})
+}
+
+func (r *shareLinkRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.ShareLink, error) {
+	return r.get(ctx, "id = ?", id)
+}
+
+func (r *shareLinkRepo) GetByToken(ctx context.Context, token string) (*models.ShareLink, error) {
+	return r.get(ctx, "token = ?", token)
+}
+
+// get loads a single link, including its ordered media IDs
+func (r *shareLinkRepo) get(ctx context.Context, query string, arg any) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := r.db.WithContext(ctx).Where(query, arg).First(&link).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.WithContext(ctx).
+		Model(&models.ShareLinkItem{}).
+		Where("link_id = ?", link.ID).
+		Order("position").
+		Pluck("media_id", &link.MediaIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *shareLinkRepo) List(ctx context.Context, householdID uuid.UUID, createdBy *uuid.UUID) ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	db := r.db.WithContext(ctx).Where("household_id = ?", householdID)
+	if createdBy != nil {
+		db = db.Where("created_by = ?", *createdBy)
+	}
+	if err := db.Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (r *shareLinkRepo) Revoke(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.ShareLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *shareLinkRepo) IncrementViews(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.ShareLink{}).
+		Where("id = ?", id).
+		UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error
+}
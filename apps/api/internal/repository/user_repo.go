@@ -5,6 +5,7 @@ import (
 
 	"storage-api/internal/models"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -12,6 +13,9 @@ import (
 type UserRepository interface {
 	GetByExternalSub(ctx context.Context, sub string) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	ListByHousehold(ctx context.Context, householdID uuid.UUID) ([]models.User, error)
+	Create(ctx context.Context, user *models.User) error
 }
 
 type userRepo struct {
@@ -46,3 +50,31 @@ func (r *userRepo) GetByEmail(ctx context.Context, email string) (*models.User,
 	}
 	return &user, nil
 }
+
+func (r *userRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) ListByHousehold(ctx context.Context, householdID uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).
+		Where("household_id = ?", householdID).
+		Order("created_at").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepo) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"storage-api/internal/config"
 	"storage-api/internal/db"
@@ -17,10 +19,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// trashPurgeInterval is how often expired trash is purged
+const trashPurgeInterval = time.Hour
+
+// hlsPollInterval is how often the HLS transcoder checks for queued videos
+// (uploads wake it immediately)
+const hlsPollInterval = 5 * time.Minute
+
+// webVideoPollInterval is how often the web video transcoder checks for
+// queued videos, e.g. from imports (uploads wake it immediately)
+const webVideoPollInterval = 5 * time.Minute
+
 type Server struct {
 	config config.Config
 	db     *gorm.DB
 	router *chi.Mux
+
+	// Background jobs started by Start and stopped by Close
+	jobs   []func(ctx context.Context)
+	cancel context.CancelFunc
 }
 
 func New(cfg config.Config) (*Server, error) {
@@ -52,7 +69,7 @@ func (s *Server) setupRoutes() {
 	s.router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"}, // Configure for production
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Household-ID"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Household-ID", handlers.SharePasswordHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -61,23 +78,68 @@ func (s *Server) setupRoutes() {
 	mediaRepo := repository.NewMediaRepository(s.db)
 	userRepo := repository.NewUserRepository(s.db)
 	householdRepo := repository.NewHouseholdRepository(s.db)
+	smartAlbumRepo := repository.NewSmartAlbumRepository(s.db)
+	commentRepo := repository.NewCommentRepository(s.db)
+	shareRepo := repository.NewShareLinkRepository(s.db)
 
 	// Initialize services
 	mediaSvc := service.NewMediaService(mediaRepo)
 	userSvc := service.NewUserService(userRepo)
 	householdSvc := service.NewHouseholdService(householdRepo)
+	smartAlbumSvc := service.NewSmartAlbumService(smartAlbumRepo, mediaRepo)
+	commentSvc := service.NewCommentService(commentRepo)
+	shareSvc := service.NewShareService(shareRepo)
+
+	// Signed media URLs
+	signer := service.NewMediaURLSigner(s.config.MediaURLKeys, s.config.MediaURLTTL)
+	if signer.Ephemeral() {
+		log.Println("WARNING: MEDIA_URL_KEYS not set, signed media URLs will not survive a restart")
+	}
 
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler(s.db)
 	userHandler := handlers.NewUserHandler(userSvc)
 	logsHandler := handlers.NewLogsHandler()
-	mediaHandler := handlers.NewMediaHandler(mediaSvc, userSvc)
+	mediaHandler := handlers.NewMediaHandler(mediaSvc, userSvc, signer)
+	if len(s.config.UploadAllowedTypes) > 0 {
+		mediaHandler.SetAllowedUploadTypes(s.config.UploadAllowedTypes)
+	}
+	handlers.SetMaxImagePixels(s.config.MaxImagePixels)
 	householdsHandler := handlers.NewHouseholdsHandler(householdSvc)
+	smartAlbumHandler := handlers.NewSmartAlbumHandler(smartAlbumSvc, userSvc, signer)
+	commentHandler := handlers.NewCommentHandler(commentSvc, mediaSvc, userSvc)
+	adminHandler := handlers.NewAdminHandler(householdSvc, userSvc, mediaSvc)
+	shareHandler := handlers.NewShareHandler(shareSvc, mediaSvc, userSvc, signer)
+
+	// Background jobs
+	if s.config.TrashRetention > 0 {
+		s.jobs = append(s.jobs, func(ctx context.Context) {
+			mediaHandler.RunTrashPurge(ctx, s.config.TrashRetention, trashPurgeInterval)
+		})
+	}
+	s.jobs = append(s.jobs, func(ctx context.Context) {
+		mediaHandler.RunWebVideoTranscoder(ctx, webVideoPollInterval)
+	})
+	if s.config.HLSMinDuration > 0 {
+		mediaHandler.EnableHLS(s.config.HLSMinDuration)
+		s.jobs = append(s.jobs, func(ctx context.Context) {
+			mediaHandler.RunHLSTranscoder(ctx, hlsPollInterval)
+		})
+	}
 
 	// Public routes (no auth required)
 	s.router.Get("/health", healthHandler.Health)
 	s.router.Get("/health/db", healthHandler.HealthDB)
 
+	// Signed media routes (authorized by the URL signature)
+	s.router.Get("/signed/media/{id}/{kind}", mediaHandler.Signed)
+
+	// Public share link routes (authorized by the link token)
+	s.router.Get("/share/{token}", shareHandler.GetShared)
+	s.router.Get("/share/{token}/media/{id}/thumbnail", shareHandler.Thumbnail)
+	s.router.Get("/share/{token}/media/{id}/download", shareHandler.Download)
+	s.router.Get("/share/{token}/media/{id}/original", shareHandler.Original)
+
 	// Protected routes (require Clerk JWT)
 	s.router.Group(func(r chi.Router) {
 		r.Use(middleware.ClerkAuth())
@@ -91,23 +153,73 @@ func (s *Server) setupRoutes() {
 		// Households routes
 		r.Get("/households", householdsHandler.List)
 
+		// Admin routes
+		r.Get("/admin/export", adminHandler.Export)
+
 		// Media routes
 		r.Post("/media/upload", mediaHandler.Upload)
 		r.Get("/media", mediaHandler.List)
+		r.Get("/media/trash", mediaHandler.ListTrash)
+		r.Delete("/media/trash", mediaHandler.EmptyTrash)
+		r.Post("/media/bulk", mediaHandler.Bulk)
+		r.Get("/media/zip", mediaHandler.Zip)
+		r.Post("/media/zip", mediaHandler.Zip)
+		r.Post("/media/archive", mediaHandler.Archive)
+		r.Post("/media/unarchive", mediaHandler.Unarchive)
 		r.Get("/media/{id}", mediaHandler.Get)
 		r.Get("/media/{id}/download", mediaHandler.Download)
 		r.Get("/media/{id}/thumbnail", mediaHandler.Thumbnail)
 		r.Get("/media/{id}/original", mediaHandler.Original)
+		r.Get("/media/{id}/image", mediaHandler.Image)
+		r.Get("/media/{id}/hls/*", mediaHandler.HLS)
+		r.Put("/media/{id}/poster", mediaHandler.Poster)
+		r.Post("/media/{id}/trim", mediaHandler.Trim)
+		r.Post("/media/{id}/frame", mediaHandler.Frame)
 		r.Delete("/media/{id}", mediaHandler.Delete)
+		r.Post("/media/{id}/restore", mediaHandler.Restore)
+		r.Put("/media/{id}/favorite", mediaHandler.Favorite)
+		r.Delete("/media/{id}/favorite", mediaHandler.Unfavorite)
+		r.Put("/media/{id}/rating", mediaHandler.Rate)
+
+		// Comment and reaction routes
+		r.Get("/media/{id}/comments", commentHandler.List)
+		r.Post("/media/{id}/comments", commentHandler.Create)
+		r.Put("/media/{id}/comments/{commentId}", commentHandler.Update)
+		r.Delete("/media/{id}/comments/{commentId}", commentHandler.Delete)
+		r.Get("/media/{id}/reactions", commentHandler.ListReactions)
+		r.Post("/media/{id}/reactions", commentHandler.AddReaction)
+		r.Delete("/media/{id}/reactions", commentHandler.RemoveReaction)
+
+		// Share link routes
+		r.Get("/shares", shareHandler.List)
+		r.Post("/shares", shareHandler.Create)
+		r.Delete("/shares/{id}", shareHandler.Revoke)
+
+		// Smart album routes
+		r.Get("/smart-albums", smartAlbumHandler.List)
+		r.Post("/smart-albums", smartAlbumHandler.Create)
+		r.Get("/smart-albums/{id}", smartAlbumHandler.Get)
+		r.Put("/smart-albums/{id}", smartAlbumHandler.Update)
+		r.Delete("/smart-albums/{id}", smartAlbumHandler.Delete)
+		r.Get("/smart-albums/{id}/media", smartAlbumHandler.Media)
 	})
 }
 
 func (s *Server) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, job := range s.jobs {
+		go job(ctx)
+	}
+
 	log.Printf("API listening on %s", s.config.Addr)
 	return http.ListenAndServe(s.config.Addr, s.router)
 }
 
 func (s *Server) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
 	if s.db != nil {
 		db.Close(s.db)
 	}
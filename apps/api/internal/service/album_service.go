@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+
+	"storage-api/internal/models"
+	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// SmartAlbumService handles business logic for smart albums
+type SmartAlbumService struct {
+	repo      repository.SmartAlbumRepository
+	mediaRepo repository.MediaRepository
+}
+
+// NewSmartAlbumService creates a new SmartAlbumService
+func NewSmartAlbumService(repo repository.SmartAlbumRepository, mediaRepo repository.MediaRepository) *SmartAlbumService {
+	return &SmartAlbumService{repo: repo, mediaRepo: mediaRepo}
+}
+
+// Create validates and saves a new smart album
+func (s *SmartAlbumService) Create(ctx context.Context, album *models.SmartAlbum) error {
+	if err := album.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, album)
+}
+
+// GetByID retrieves a smart album by ID
+func (s *SmartAlbumService) GetByID(ctx context.Context, id uuid.UUID) (*models.SmartAlbum, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns all smart albums of a household
+func (s *SmartAlbumService) List(ctx context.Context, householdID uuid.UUID) ([]models.SmartAlbum, error) {
+	return s.repo.List(ctx, householdID)
+}
+
+// Update validates and saves the name and rules of a smart album
+func (s *SmartAlbumService) Update(ctx context.Context, album *models.SmartAlbum) error {
+	if err := album.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, album)
+}
+
+// Delete removes a smart album by ID. Media items are not affected.
+func (s *SmartAlbumService) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// ListMedia evaluates the album's rules and returns a page of matching media,
+// applying the same visibility filtering as the main listing.
+func (s *SmartAlbumService) ListMedia(ctx context.Context, album *models.SmartAlbum, userID *uuid.UUID, page, pageSize int) ([]models.MediaItem, int64, error) {
+	filter := repository.MediaListFilter{
+		HouseholdID: album.HouseholdID,
+		UserID:      userID,
+		Visibility:  "all",
+		Page:        page,
+		PageSize:    pageSize,
+	}
+	applyAlbumRules(&filter, album.Rules)
+	normalizePaging(&filter)
+	return s.mediaRepo.List(ctx, filter)
+}
+
+// applyAlbumRules translates smart album rules into media list filter options
+func applyAlbumRules(filter *repository.MediaListFilter, rules models.SmartAlbumRules) {
+	filter.MediaType = rules.MediaType
+	filter.UploaderIDs = rules.UploaderIDs
+	filter.TakenAfter = rules.TakenAfter
+	filter.TakenBefore = rules.TakenBefore
+	filter.HasLocation = rules.HasLocation
+	filter.Bounds = rules.Bounds
+	filter.CameraMake = rules.CameraMake
+	filter.CameraModel = rules.CameraModel
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"storage-api/internal/models"
+	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// CommentService handles business logic for comments and reactions
+type CommentService struct {
+	repo repository.CommentRepository
+}
+
+// NewCommentService creates a new CommentService
+func NewCommentService(repo repository.CommentRepository) *CommentService {
+	return &CommentService{repo: repo}
+}
+
+// Create validates and saves a new comment. A reply must belong to the same item
+// as its parent.
+func (s *CommentService) Create(ctx context.Context, comment *models.Comment) error {
+	if err := comment.Validate(); err != nil {
+		return err
+	}
+
+	if comment.ParentID != nil {
+		parent, err := s.repo.GetByID(ctx, *comment.ParentID)
+		if err != nil {
+			return fmt.Errorf("%w: parent comment not found", models.ErrInvalidInput)
+		}
+		if parent.MediaID != comment.MediaID {
+			return fmt.Errorf("%w: parent comment belongs to another item", models.ErrInvalidInput)
+		}
+	}
+
+	if err := s.repo.Create(ctx, comment); err != nil {
+		return err
+	}
+
+	// Reload to include author details
+	saved, err := s.repo.GetByID(ctx, comment.ID)
+	if err != nil {
+		return err
+	}
+	*comment = *saved
+	return nil
+}
+
+// GetByID retrieves a comment by ID
+func (s *CommentService) GetByID(ctx context.Context, id uuid.UUID) (*models.Comment, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListByMedia returns all comments on an item, oldest first
+func (s *CommentService) ListByMedia(ctx context.Context, mediaID uuid.UUID) ([]models.Comment, error) {
+	return s.repo.ListByMedia(ctx, mediaID)
+}
+
+// UpdateBody validates and replaces the body of a comment, marking it as edited
+func (s *CommentService) UpdateBody(ctx context.Context, comment *models.Comment, body string) error {
+	comment.Body = body
+	if err := comment.Validate(); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateBody(ctx, comment.ID, comment.Body); err != nil {
+		return err
+	}
+
+	saved, err := s.repo.GetByID(ctx, comment.ID)
+	if err != nil {
+		return err
+	}
+	*comment = *saved
+	return nil
+}
+
+// Delete removes a comment and its replies
+func (s *CommentService) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// AddReaction records a user's emoji reaction on an item (idempotent)
+func (s *CommentService) AddReaction(ctx context.Context, mediaID, userID uuid.UUID, emoji string) error {
+	if err := models.ValidateEmoji(emoji); err != nil {
+		return err
+	}
+	return s.repo.AddReaction(ctx, &models.Reaction{MediaID: mediaID, UserID: userID, Emoji: emoji})
+}
+
+// RemoveReaction removes a user's emoji reaction from an item
+func (s *CommentService) RemoveReaction(ctx context.Context, mediaID, userID uuid.UUID, emoji string) error {
+	return s.repo.RemoveReaction(ctx, mediaID, userID, emoji)
+}
+
+// ReactionSummary returns the reactions on an item grouped by emoji,
+// in the order each emoji was first used
+func (s *CommentService) ReactionSummary(ctx context.Context, mediaID uuid.UUID) ([]models.ReactionSummary, error) {
+	reactions, err := s.repo.ListReactions(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := []models.ReactionSummary{}
+	index := make(map[string]int)
+	for _, reaction := range reactions {
+		i, ok := index[reaction.Emoji]
+		if !ok {
+			i = len(summary)
+			index[reaction.Emoji] = i
+			summary = append(summary, models.ReactionSummary{Emoji: reaction.Emoji})
+		}
+		summary[i].Count++
+		summary[i].UserIDs = append(summary[i].UserIDs, reaction.UserID)
+	}
+	return summary, nil
+}
@@ -5,6 +5,8 @@ import (
 
 	"storage-api/internal/models"
 	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
 )
 
 // HouseholdService handles business logic for household operations
@@ -21,3 +23,13 @@ func NewHouseholdService(repo repository.HouseholdRepository) *HouseholdService
 func (s *HouseholdService) List(ctx context.Context) ([]models.Household, error) {
 	return s.repo.List(ctx)
 }
+
+// GetByID retrieves a household by ID
+func (s *HouseholdService) GetByID(ctx context.Context, id uuid.UUID) (*models.Household, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Create creates a new household
+func (s *HouseholdService) Create(ctx context.Context, household *models.Household) error {
+	return s.repo.Create(ctx, household)
+}
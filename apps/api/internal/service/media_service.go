@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"storage-api/internal/models"
 	"storage-api/internal/repository"
@@ -34,19 +36,207 @@ func (s *MediaService) GetByPath(ctx context.Context, householdID uuid.UUID, pat
 	return s.repo.GetByPath(ctx, householdID, path)
 }
 
+// GetByIDs retrieves all media items with the given IDs (missing IDs are skipped)
+func (s *MediaService) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]models.MediaItem, error) {
+	return s.repo.GetByIDs(ctx, ids)
+}
+
+// GetByIDsIncludingTrash is like GetByIDs but also returns trashed items
+func (s *MediaService) GetByIDsIncludingTrash(ctx context.Context, ids []uuid.UUID) ([]models.MediaItem, error) {
+	return s.repo.GetByIDsIncludingTrash(ctx, ids)
+}
+
+// GetBySHA256 finds an item in the household with the given content hash
+func (s *MediaService) GetBySHA256(ctx context.Context, householdID uuid.UUID, sha256 string) (*models.MediaItem, error) {
+	return s.repo.GetBySHA256(ctx, householdID, sha256)
+}
+
+// ListAllByHousehold returns every item of a household, including archived and trashed ones
+func (s *MediaService) ListAllByHousehold(ctx context.Context, householdID uuid.UUID) ([]models.MediaItem, error) {
+	return s.repo.ListAllByHousehold(ctx, householdID)
+}
+
 // List retrieves paginated media items for a household with visibility filtering
 func (s *MediaService) List(ctx context.Context, filter repository.MediaListFilter) ([]models.MediaItem, int64, error) {
-	// Apply defaults and limits
+	normalizePaging(&filter)
+	return s.repo.List(ctx, filter)
+}
+
+// normalizePaging applies default page and page size limits to a filter
+func normalizePaging(filter *repository.MediaListFilter) {
 	if filter.PageSize < 1 || filter.PageSize > 100 {
 		filter.PageSize = 20
 	}
 	if filter.Page < 1 {
 		filter.Page = 1
 	}
-	return s.repo.List(ctx, filter)
 }
 
-// Delete removes a media item by ID
+// Delete moves a media item to the trash. Files are kept until it is purged.
 func (s *MediaService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.repo.Delete(ctx, id)
 }
+
+// GetTrashedByID retrieves a media item that is in the trash
+func (s *MediaService) GetTrashedByID(ctx context.Context, id uuid.UUID) (*models.MediaItem, error) {
+	return s.repo.GetTrashedByID(ctx, id)
+}
+
+// ListTrashed returns all trashed items of a household, optionally limited to one uploader
+func (s *MediaService) ListTrashed(ctx context.Context, householdID uuid.UUID, uploaderID *uuid.UUID) ([]models.MediaItem, error) {
+	return s.repo.ListTrashed(ctx, householdID, uploaderID)
+}
+
+// ListTrashedBefore returns trashed items (across households) deleted before the cutoff
+func (s *MediaService) ListTrashedBefore(ctx context.Context, cutoff time.Time) ([]models.MediaItem, error) {
+	return s.repo.ListTrashedBefore(ctx, cutoff)
+}
+
+// Restore moves a trashed media item back to the library
+func (s *MediaService) Restore(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Restore(ctx, id)
+}
+
+// Purge permanently deletes a trashed item's row. Returns false if the item
+// was not in the trash (e.g. it was restored concurrently).
+func (s *MediaService) Purge(ctx context.Context, id uuid.UUID) (bool, error) {
+	n, err := s.repo.Purge(ctx, []uuid.UUID{id})
+	return n > 0, err
+}
+
+// SetArchived archives or unarchives the given items, returning the number updated
+func (s *MediaService) SetArchived(ctx context.Context, ids []uuid.UUID, archived bool) (int64, error) {
+	return s.repo.SetArchived(ctx, ids, archived)
+}
+
+// SetPrivate changes whether an item is private to its uploader
+func (s *MediaService) SetPrivate(ctx context.Context, id uuid.UUID, isPrivate bool) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{"is_private": isPrivate})
+}
+
+// SetTakenAt overrides the capture date of an item
+func (s *MediaService) SetTakenAt(ctx context.Context, id uuid.UUID, takenAt time.Time) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{"taken_at": takenAt.UTC()})
+}
+
+// SetPoster records a video's regenerated thumbnail and the frame it came from
+func (s *MediaService) SetPoster(ctx context.Context, id uuid.UUID, thumbnailPath, blurHash, dominantColor string, timeSec float64) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{
+		"thumbnail_path":  thumbnailPath,
+		"blur_hash":       blurHash,
+		"dominant_color":  dominantColor,
+		"poster_time_sec": timeSec,
+	})
+}
+
+// SetDocumentText stores the text extracted from a document for search
+func (s *MediaService) SetDocumentText(ctx context.Context, id uuid.UUID, content string) error {
+	return s.repo.SetDocumentText(ctx, id, content)
+}
+
+// FindLivePhotoCandidates returns unpaired items of mediaType that may be the
+// other half of a Live Photo, by content identifier or file name
+func (s *MediaService) FindLivePhotoCandidates(ctx context.Context, householdID uuid.UUID, mediaType, contentID, filenameBase string) ([]models.MediaItem, error) {
+	return s.repo.FindLivePhotoCandidates(ctx, householdID, mediaType, contentID, filenameBase)
+}
+
+// LinkLivePhoto pairs a Live Photo still with its motion video
+func (s *MediaService) LinkLivePhoto(ctx context.Context, photoID, videoID uuid.UUID) error {
+	return s.Transaction(ctx, func(tx *MediaService) error {
+		if err := tx.repo.UpdateFields(ctx, photoID, map[string]any{"live_photo_video_id": videoID}); err != nil {
+			return err
+		}
+		return tx.repo.UpdateFields(ctx, videoID, map[string]any{"live_photo_id": photoID})
+	})
+}
+
+// FindStackCandidates returns unstacked photos with the given file name (any
+// extension) that may be the other half of a RAW+JPEG pair
+func (s *MediaService) FindStackCandidates(ctx context.Context, householdID uuid.UUID, filenameBase string) ([]models.MediaItem, error) {
+	return s.repo.FindStackCandidates(ctx, householdID, filenameBase)
+}
+
+// StackRAW stacks a RAW file under the JPEG shot with it
+func (s *MediaService) StackRAW(ctx context.Context, jpegID, rawID uuid.UUID) error {
+	return s.Transaction(ctx, func(tx *MediaService) error {
+		if err := tx.repo.UpdateFields(ctx, jpegID, map[string]any{"stacked_raw_id": rawID}); err != nil {
+			return err
+		}
+		return tx.repo.UpdateFields(ctx, rawID, map[string]any{"stack_parent_id": jpegID})
+	})
+}
+
+// NextHLSCandidate returns the next video that needs an HLS stream, or
+// models.ErrNotFound if there is none
+func (s *MediaService) NextHLSCandidate(ctx context.Context, minDuration time.Duration) (*models.MediaItem, error) {
+	return s.repo.NextHLSCandidate(ctx, int(minDuration.Seconds()))
+}
+
+// ResetHLSProcessing requeues HLS generation interrupted by a restart
+func (s *MediaService) ResetHLSProcessing(ctx context.Context) (int64, error) {
+	return s.repo.ResetHLSProcessing(ctx)
+}
+
+// SetHLSStatus records the state and progress (0-100) of HLS generation
+func (s *MediaService) SetHLSStatus(ctx context.Context, id uuid.UUID, status string, progress int) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{"hls_status": status, "hls_progress": progress})
+}
+
+// SetHLSReady records a finished HLS stream's master playlist
+func (s *MediaService) SetHLSReady(ctx context.Context, id uuid.UUID, path string) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{
+		"hls_status":   models.HLSStatusReady,
+		"hls_progress": 100,
+		"hls_path":     path,
+	})
+}
+
+// NextWebVideoCandidate returns the next video that needs a web rendition, or
+// models.ErrNotFound if there is none
+func (s *MediaService) NextWebVideoCandidate(ctx context.Context) (*models.MediaItem, error) {
+	return s.repo.NextWebVideoCandidate(ctx)
+}
+
+// ResetWebVideoProcessing requeues web renditions interrupted by a restart
+func (s *MediaService) ResetWebVideoProcessing(ctx context.Context) (int64, error) {
+	return s.repo.ResetWebVideoProcessing(ctx)
+}
+
+// SetWebVideoStatus records the state of a video's web rendition
+func (s *MediaService) SetWebVideoStatus(ctx context.Context, id uuid.UUID, status string) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{"web_video_status": status})
+}
+
+// SetWebVideoReady records a finished web rendition
+func (s *MediaService) SetWebVideoReady(ctx context.Context, id uuid.UUID, path string) error {
+	return s.repo.UpdateFields(ctx, id, map[string]any{
+		"web_video_status": "",
+		"web_path":         path,
+	})
+}
+
+// Transaction runs fn with a MediaService whose operations share one database
+// transaction. The transaction commits only if fn returns nil.
+func (s *MediaService) Transaction(ctx context.Context, fn func(tx *MediaService) error) error {
+	return s.repo.Transaction(ctx, func(repo repository.MediaRepository) error {
+		return fn(&MediaService{repo: repo})
+	})
+}
+
+// GetUserState returns a user's favorite/rating state for an item
+func (s *MediaService) GetUserState(ctx context.Context, mediaID, userID uuid.UUID) (*models.MediaUserState, error) {
+	return s.repo.GetUserState(ctx, mediaID, userID)
+}
+
+// SetFavorite marks or unmarks an item as one of the user's favorites
+func (s *MediaService) SetFavorite(ctx context.Context, mediaID, userID uuid.UUID, favorite bool) error {
+	return s.repo.SetFavorite(ctx, mediaID, userID, favorite)
+}
+
+// SetRating sets the user's 0-5 star rating for an item (0 clears it)
+func (s *MediaService) SetRating(ctx context.Context, mediaID, userID uuid.UUID, rating int) error {
+	if rating < 0 || rating > models.MaxRating {
+		return fmt.Errorf("%w: rating must be between 0 and %d", models.ErrInvalidInput, models.MaxRating)
+	}
+	return s.repo.SetRating(ctx, mediaID, userID, rating)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"storage-api/internal/models"
+	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// shareTokenBytes is the amount of randomness in a share token
+const shareTokenBytes = 32
+
+// Wrong passwords allowed per link within sharePasswordWindow before
+// CheckPassword refuses to try more
+const (
+	sharePasswordMaxFailures = 10
+	sharePasswordWindow      = 15 * time.Minute
+)
+
+// ShareService handles business logic for public share links
+type ShareService struct {
+	repo repository.ShareLinkRepository
+
+	mu       sync.Mutex
+	failures map[uuid.UUID][]time.Time // Recent wrong passwords per link
+}
+
+// NewShareService creates a new ShareService
+func NewShareService(repo repository.ShareLinkRepository) *ShareService {
+	return &ShareService{repo: repo, failures: make(map[uuid.UUID][]time.Time)}
+}
+
+// Create validates the link, generates its token, hashes the password (if any)
+// and saves it together with link.MediaIDs.
+func (s *ShareService) Create(ctx context.Context, link *models.ShareLink, password string) error {
+	if len(link.MediaIDs) == 0 {
+		return fmt.Errorf("%w: at least one item is required", models.ErrInvalidInput)
+	}
+	if len(link.MediaIDs) > models.MaxShareItems {
+		return fmt.Errorf("%w: at most %d items can be shared", models.ErrInvalidInput, models.MaxShareItems)
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: expiresAt must be in the future", models.ErrInvalidInput)
+	}
+	if len(link.Title) > 255 {
+		return fmt.Errorf("%w: title is too long", models.ErrInvalidInput)
+	}
+
+	if password != "" {
+		// bcrypt ignores input beyond 72 bytes
+		if len(password) > 72 {
+			return fmt.Errorf("%w: password is too long", models.ErrInvalidInput)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return err
+	}
+	link.Token = token
+
+	if err := s.repo.Create(ctx, link); err != nil {
+		return err
+	}
+	link.HasPassword = link.PasswordHash != ""
+	return nil
+}
+
+// newShareToken returns a random URL-safe token
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GetByID retrieves a share link by ID
+func (s *ShareService) GetByID(ctx context.Context, id uuid.UUID) (*models.ShareLink, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns the household's share links, newest first.
+// If createdBy is set, only that user's links are returned.
+func (s *ShareService) List(ctx context.Context, householdID uuid.UUID, createdBy *uuid.UUID) ([]models.ShareLink, error) {
+	return s.repo.List(ctx, householdID, createdBy)
+}
+
+// Revoke disables a share link permanently
+func (s *ShareService) Revoke(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Revoke(ctx, id)
+}
+
+// Resolve returns the active link for a token. Revoked, expired and unknown
+// tokens all return ErrNotFound so callers cannot tell them apart.
+// The password, if the link has one, is checked separately by CheckPassword.
+func (s *ShareService) Resolve(ctx context.Context, token string) (*models.ShareLink, error) {
+	link, err := s.repo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !link.IsActive(time.Now()) {
+		return nil, models.ErrNotFound
+	}
+	return link, nil
+}
+
+// CheckPassword returns ErrPasswordRequired if password does not match the
+// link's. After too many wrong passwords in a short time it returns
+// ErrTooManyAttempts without checking, which bounds both guessing and the
+// bcrypt work an anonymous client can cause.
+func (s *ShareService) CheckPassword(link *models.ShareLink, password string) error {
+	if link.PasswordHash == "" {
+		return nil
+	}
+	if password == "" {
+		return models.ErrPasswordRequired
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	recent := s.recentFailures(link.ID, now)
+	s.mu.Unlock()
+	if recent >= sharePasswordMaxFailures {
+		return models.ErrTooManyAttempts
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+		s.mu.Lock()
+		s.failures[link.ID] = append(s.failures[link.ID], now)
+		s.mu.Unlock()
+		return models.ErrPasswordRequired
+	}
+	return nil
+}
+
+// recentFailures drops failures older than sharePasswordWindow, for every
+// link so the map doesn't grow, and returns the count left for id.
+// The caller must hold s.mu.
+func (s *ShareService) recentFailures(id uuid.UUID, now time.Time) int {
+	for linkID, times := range s.failures {
+		for len(times) > 0 && now.Sub(times[0]) > sharePasswordWindow {
+			times = times[1:]
+		}
+		if len(times) == 0 {
+			delete(s.failures, linkID)
+		} else {
+			s.failures[linkID] = times
+		}
+	}
+	return len(s.failures[id])
+}
+
+// RecordView increments the link's view counter
+func (s *ShareService) RecordView(ctx context.Context, id uuid.UUID) error {
+	return s.repo.IncrementViews(ctx, id)
+}
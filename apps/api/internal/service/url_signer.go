@@ -0,0 +1,159 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"storage-api/internal/config"
+	"storage-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidSignature is returned for tampered, expired or unknown-key URLs
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// shareAccessKind is signed in place of a derivative kind in share access
+// tokens, so a token can never pass as a media URL signature
+const shareAccessKind = "share-access"
+
+// MediaURLSigner creates and verifies HMAC-signed media URLs. A signature is
+// bound to the item, the derivative kind and the expiry time.
+type MediaURLSigner struct {
+	keys []config.SigningKey
+	ttl  time.Duration
+}
+
+// NewMediaURLSigner creates a signer. The first key signs; all keys verify.
+// With no keys a random one is generated, so URLs only work until restart
+// and only on this instance.
+func NewMediaURLSigner(keys []config.SigningKey, ttl time.Duration) *MediaURLSigner {
+	if len(keys) == 0 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate media URL key: %v", err))
+		}
+		keys = []config.SigningKey{{ID: "ephemeral", Secret: secret}}
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &MediaURLSigner{keys: keys, ttl: ttl}
+}
+
+// Ephemeral reports whether the signer is using a generated key
+func (s *MediaURLSigner) Ephemeral() bool {
+	return s.keys[0].ID == "ephemeral"
+}
+
+// URLs returns signed URLs for all derivatives of an item.
+//
+// The expiry is rounded to a TTL boundary so that URLs stay identical for a
+// while and browsers can cache the images; each URL is valid for between one
+// and two TTLs.
+func (s *MediaURLSigner) URLs(item *models.MediaItem) *models.MediaURLs {
+	exp := s.expiry()
+
+	urls := &models.MediaURLs{
+		Download:  s.sign(item.ID, models.MediaKindDownload, exp),
+		Original:  s.sign(item.ID, models.MediaKindOriginal, exp),
+		ExpiresAt: exp.UTC(),
+	}
+	if item.ThumbnailPath != "" {
+		// v changes when the thumbnail is regenerated, so caches don't serve
+		// a stale poster frame within one signing window
+		urls.Thumbnail = s.sign(item.ID, models.MediaKindThumbnail, exp) +
+			"&v=" + strconv.FormatInt(item.UpdatedAt.Unix(), 10)
+	}
+	return urls
+}
+
+// TrashURLs returns a signed thumbnail URL for an item in the trash. Only
+// the thumbnail is served for trashed items, and only through this kind.
+func (s *MediaURLSigner) TrashURLs(item *models.MediaItem) *models.MediaURLs {
+	exp := s.expiry()
+
+	urls := &models.MediaURLs{ExpiresAt: exp.UTC()}
+	if item.ThumbnailPath != "" {
+		urls.Thumbnail = s.sign(item.ID, models.MediaKindTrashThumbnail, exp)
+	}
+	return urls
+}
+
+// expiry returns the expiry for URLs signed now, rounded as described on URLs
+func (s *MediaURLSigner) expiry() time.Time {
+	return time.Now().Truncate(s.ttl).Add(2 * s.ttl)
+}
+
+// sign returns the signed path for one derivative
+func (s *MediaURLSigner) sign(id uuid.UUID, kind string, exp time.Time) string {
+	key := s.keys[0]
+	expStr := strconv.FormatInt(exp.Unix(), 10)
+
+	q := url.Values{}
+	q.Set("exp", expStr)
+	q.Set("kid", key.ID)
+	q.Set("sig", base64.RawURLEncoding.EncodeToString(mac(key.Secret, id, kind, expStr)))
+	return fmt.Sprintf("/signed/media/%s/%s?%s", id, kind, q.Encode())
+}
+
+// Verify checks the exp, kid and sig query values of a signed URL
+func (s *MediaURLSigner) Verify(id uuid.UUID, kind string, q url.Values) error {
+	return s.verify(id, kind, q.Get("exp"), q.Get("kid"), q.Get("sig"))
+}
+
+// ShareAccessToken returns a token granting access to a password-protected
+// share link's media without the password. It expires after one TTL.
+func (s *MediaURLSigner) ShareAccessToken(linkID uuid.UUID) (token string, exp time.Time) {
+	key := s.keys[0]
+	exp = time.Now().Add(s.ttl)
+	expStr := strconv.FormatInt(exp.Unix(), 10)
+	sig := base64.RawURLEncoding.EncodeToString(mac(key.Secret, linkID, shareAccessKind, expStr))
+	// Neither exp nor the base64url signature contains a dot; the key ID might
+	return expStr + "." + sig + "." + key.ID, exp
+}
+
+// VerifyShareAccess checks a token from ShareAccessToken for the link
+func (s *MediaURLSigner) VerifyShareAccess(linkID uuid.UUID, token string) error {
+	expStr, rest, _ := strings.Cut(token, ".")
+	sig, kid, _ := strings.Cut(rest, ".")
+	return s.verify(linkID, shareAccessKind, expStr, kid, sig)
+}
+
+// verify checks a signature made by mac with the key kid
+func (s *MediaURLSigner) verify(id uuid.UUID, kind, expStr, kid, sigStr string) error {
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || time.Now().Unix() >= exp {
+		return ErrInvalidSignature
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sigStr)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	for _, key := range s.keys {
+		if key.ID == kid {
+			if hmac.Equal(sig, mac(key.Secret, id, kind, expStr)) {
+				return nil
+			}
+			return ErrInvalidSignature
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// mac computes the signature over the item, kind and expiry
+func mac(secret []byte, id uuid.UUID, kind, exp string) []byte {
+	h := hmac.New(sha256.New, secret)
+	fmt.Fprintf(h, "%s\n%s\n%s", id, kind, exp)
+	return h.Sum(nil)
+}
@@ -5,6 +5,8 @@ import (
 
 	"storage-api/internal/models"
 	"storage-api/internal/repository"
+
+	"github.com/google/uuid"
 )
 
 // UserService handles business logic for user operations
@@ -26,3 +28,18 @@ func (s *UserService) GetByExternalSub(ctx context.Context, sub string) (*models
 func (s *UserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	return s.repo.GetByEmail(ctx, email)
 }
+
+// GetByID retrieves a user by ID
+func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListByHousehold returns all members of a household
+func (s *UserService) ListByHousehold(ctx context.Context, householdID uuid.UUID) ([]models.User, error) {
+	return s.repo.ListByHousehold(ctx, householdID)
+}
+
+// Create creates a new user
+func (s *UserService) Create(ctx context.Context, user *models.User) error {
+	return s.repo.Create(ctx, user)
+}
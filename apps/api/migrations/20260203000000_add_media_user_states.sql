@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE media_user_states (
+  media_id UUID NOT NULL REFERENCES storage_items(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  is_favorite BOOLEAN NOT NULL DEFAULT false,
+  rating SMALLINT NOT NULL DEFAULT 0 CHECK (rating BETWEEN 0 AND 5),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (media_id, user_id)
+);
+
+CREATE INDEX idx_media_user_states_user_id ON media_user_states(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS media_user_states;
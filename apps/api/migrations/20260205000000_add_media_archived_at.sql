@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE storage_items ADD COLUMN archived_at TIMESTAMPTZ;
+
+CREATE INDEX idx_storage_items_archived_at ON storage_items(archived_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_storage_items_archived_at;
+ALTER TABLE storage_items DROP COLUMN IF EXISTS archived_at;
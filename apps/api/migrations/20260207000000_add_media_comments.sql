@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE media_comments (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  media_id UUID NOT NULL REFERENCES storage_items(id) ON DELETE CASCADE,
+  parent_id UUID REFERENCES media_comments(id) ON DELETE CASCADE,
+  author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  body TEXT NOT NULL,
+  edited_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_media_comments_media_id ON media_comments(media_id);
+CREATE INDEX idx_media_comments_parent_id ON media_comments(parent_id);
+
+CREATE TABLE media_reactions (
+  media_id UUID NOT NULL REFERENCES storage_items(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  emoji TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (media_id, user_id, emoji)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS media_reactions;
+DROP TABLE IF EXISTS media_comments;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Soft delete: trashed items keep their row and files until purged
+ALTER TABLE storage_items ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_storage_items_deleted_at ON storage_items(deleted_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_storage_items_deleted_at;
+ALTER TABLE storage_items DROP COLUMN IF EXISTS deleted_at;
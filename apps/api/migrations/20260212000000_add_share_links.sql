@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE share_links (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  household_id UUID NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  token TEXT NOT NULL UNIQUE,
+  title TEXT,
+  password_hash TEXT,
+  expires_at TIMESTAMPTZ,
+  allow_original BOOLEAN NOT NULL DEFAULT false,
+  view_count BIGINT NOT NULL DEFAULT 0,
+  revoked_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_share_links_household_id ON share_links(household_id);
+
+CREATE TABLE share_link_items (
+  link_id UUID NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+  media_id UUID NOT NULL REFERENCES storage_items(id) ON DELETE CASCADE,
+  position INT NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (link_id, media_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS share_link_items;
+DROP TABLE IF EXISTS share_links;
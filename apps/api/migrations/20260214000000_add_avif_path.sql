@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE storage_items ADD COLUMN avif_path TEXT;
+
+-- +goose Down
+ALTER TABLE storage_items DROP COLUMN IF EXISTS avif_path;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN blur_hash TEXT,
+  ADD COLUMN dominant_color TEXT;
+
+-- +goose Down
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS blur_hash,
+  DROP COLUMN IF EXISTS dominant_color;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN is_animated BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN frame_count INT,
+  ADD COLUMN animation_duration_ms INT,
+  ADD COLUMN animated_thumbnail_path TEXT;
+
+-- +goose Down
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS is_animated,
+  DROP COLUMN IF EXISTS frame_count,
+  DROP COLUMN IF EXISTS animation_duration_ms,
+  DROP COLUMN IF EXISTS animated_thumbnail_path;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN rotation INT,
+  ADD COLUMN video_codec TEXT,
+  ADD COLUMN bitrate BIGINT,
+  ADD COLUMN frame_rate DOUBLE PRECISION;
+
+-- +goose Down
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS rotation,
+  DROP COLUMN IF EXISTS video_codec,
+  DROP COLUMN IF EXISTS bitrate,
+  DROP COLUMN IF EXISTS frame_rate;
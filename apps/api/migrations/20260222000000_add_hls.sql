@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN hls_status TEXT,
+  ADD COLUMN hls_progress INT NOT NULL DEFAULT 0,
+  ADD COLUMN hls_path TEXT;
+
+-- Lets the transcoder find queued and interrupted videos quickly
+CREATE INDEX idx_storage_items_hls_status ON storage_items(hls_status)
+  WHERE hls_status IN ('pending', 'processing');
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_storage_items_hls_status;
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS hls_status,
+  DROP COLUMN IF EXISTS hls_progress,
+  DROP COLUMN IF EXISTS hls_path;
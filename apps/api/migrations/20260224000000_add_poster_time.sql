@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN poster_time_sec DOUBLE PRECISION;
+
+-- +goose Down
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS poster_time_sec;
@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN content_identifier TEXT,
+  ADD COLUMN live_photo_video_id UUID,
+  ADD COLUMN live_photo_id UUID;
+
+-- Pairing looks up the other half of a Live Photo by Apple content identifier
+CREATE INDEX idx_storage_items_content_identifier ON storage_items(household_id, content_identifier)
+  WHERE content_identifier IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_storage_items_content_identifier;
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS content_identifier,
+  DROP COLUMN IF EXISTS live_photo_video_id,
+  DROP COLUMN IF EXISTS live_photo_id;
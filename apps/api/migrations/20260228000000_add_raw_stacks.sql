@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE storage_items
+  ADD COLUMN stacked_raw_id UUID,
+  ADD COLUMN stack_parent_id UUID;
+
+-- +goose Down
+ALTER TABLE storage_items
+  DROP COLUMN IF EXISTS stacked_raw_id,
+  DROP COLUMN IF EXISTS stack_parent_id;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE storage_items DROP CONSTRAINT IF EXISTS storage_items_type_check;
+ALTER TABLE storage_items
+  ADD CONSTRAINT storage_items_type_check CHECK (type IN ('photo', 'video', 'document')),
+  ADD COLUMN page_count INT;
+
+-- Extracted text is kept out of storage_items so listings don't load it
+CREATE TABLE media_document_texts (
+  media_id UUID PRIMARY KEY REFERENCES storage_items(id) ON DELETE CASCADE,
+  content TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS media_document_texts;
+ALTER TABLE storage_items DROP CONSTRAINT IF EXISTS storage_items_type_check;
+ALTER TABLE storage_items
+  ADD CONSTRAINT storage_items_type_check CHECK (type IN ('photo', 'video')),
+  DROP COLUMN IF EXISTS page_count;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE storage_items DROP CONSTRAINT IF EXISTS storage_items_type_check;
+ALTER TABLE storage_items
+  ADD CONSTRAINT storage_items_type_check CHECK (type IN ('photo', 'video', 'document', 'audio')),
+  ADD COLUMN audio_codec TEXT,
+  ADD COLUMN title TEXT,
+  ADD COLUMN artist TEXT,
+  ADD COLUMN album TEXT;
+
+-- +goose Down
+ALTER TABLE storage_items DROP CONSTRAINT IF EXISTS storage_items_type_check;
+ALTER TABLE storage_items
+  ADD CONSTRAINT storage_items_type_check CHECK (type IN ('photo', 'video', 'document')),
+  DROP COLUMN IF EXISTS audio_codec,
+  DROP COLUMN IF EXISTS title,
+  DROP COLUMN IF EXISTS artist,
+  DROP COLUMN IF EXISTS album;
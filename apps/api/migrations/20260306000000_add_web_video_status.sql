@@ -0,0 +1,11 @@
+-- +goose Up
+-- Web renditions of videos are transcoded in the background; until one is
+-- ready, downloads serve the original
+ALTER TABLE storage_items ADD COLUMN web_video_status TEXT;
+
+CREATE INDEX idx_storage_items_web_video_status ON storage_items(web_video_status)
+  WHERE web_video_status IN ('pending', 'processing');
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_storage_items_web_video_status;
+ALTER TABLE storage_items DROP COLUMN IF EXISTS web_video_status;